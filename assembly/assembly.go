@@ -0,0 +1,224 @@
+// Package assembly contains the type P, a physical assembly plan for a game solution:
+// an order in which the blocks can be lowered vertically into the volume, one after
+// the other, without passing through blocks already placed, each coming to rest on
+// the ground or on a block below it
+package assembly
+
+import (
+	"fmt"
+	"sort"
+
+	"ubongo/base/vector"
+	"ubongo/gamesolution"
+)
+
+// P is an assembly plan of a solution
+type P struct {
+	// Solution is the solution to assemble
+	Solution *gamesolution.S
+
+	// Order contains the indices of the solution's blocks in the order of assembly
+	Order []int
+}
+
+// New creates an assembly plan for the given solution, the z-axis pointing upwards.
+// Returns nil if there is no order in which the solution can be built physically.
+// Blocks lying lower are preferred, so the plan builds the solution bottom-up.
+// Panics if the solution has more than 64 blocks
+func New(sol *gamesolution.S) *P {
+	if sol == nil {
+		panic("Solution must not be nil")
+	}
+	if len(sol.Blocks) > 64 {
+		panic("Assembly plans support at most 64 blocks")
+	}
+
+	p := &planner{
+		cells:      blockCells(sol),
+		candidates: make([]int, len(sol.Blocks)),
+		occupied:   make(map[vector.V]bool),
+		order:      make([]int, 0, len(sol.Blocks)),
+		failed:     make(map[uint64]bool)}
+
+	// try the blocks in the order of their lowest cell
+	for i, cells := range p.cells {
+		p.candidates[i] = i
+		for _, c := range cells {
+			if c[2]+1 > p.top {
+				p.top = c[2] + 1
+			}
+		}
+	}
+	sort.SliceStable(p.candidates, func(i, j int) bool {
+		return lowest(p.cells[p.candidates[i]]) < lowest(p.cells[p.candidates[j]])
+	})
+
+	if !p.search(0) {
+		return nil
+	}
+	return &P{Solution: sol, Order: p.order}
+}
+
+// IsBuildable returns true if the solution can be built physically, false if it
+// is only mathematically valid
+func IsBuildable(sol *gamesolution.S) bool {
+	return New(sol) != nil
+}
+
+// String returns a string representation of the plan
+func (p *P) String() string {
+	if p == nil {
+		return "(nil)"
+	} else {
+		s := "Assembly plan"
+		for _, step := range p.Steps() {
+			s += "\n\t" + p.Describe(step)
+		}
+		return s
+	}
+}
+
+// Step is a single step of an assembly plan, placing one block
+type Step struct {
+	// Number of the step, starting with 1
+	Number int
+
+	// BlockIdx is the index of the block placed, in the blocks of the solution
+	BlockIdx int
+
+	// Placed contains the indices of the blocks placed in the previous steps, in the order of assembly
+	Placed []int
+
+	// OnGround is true if the block stands on the ground, false if it rests on other blocks only
+	OnGround bool
+}
+
+// Steps returns the steps of the plan, one per block
+func (p *P) Steps() []Step {
+	steps := make([]Step, 0)
+	if p == nil {
+		return steps
+	}
+	cells := blockCells(p.Solution)
+	for i, blockIdx := range p.Order {
+		placed := make([]int, i)
+		copy(placed, p.Order[:i])
+		onGround := lowest(cells[blockIdx]) == 0
+		steps = append(steps, Step{Number: i + 1, BlockIdx: blockIdx, Placed: placed, OnGround: onGround})
+	}
+	return steps
+}
+
+// Describe returns a human readable description of the given step of the plan
+func (p *P) Describe(step Step) string {
+	b := p.Solution.Blocks[step.BlockIdx]
+	where := "on top of the blocks below"
+	if step.OnGround {
+		where = "on the ground"
+	}
+	return fmt.Sprintf("Step %d: lower %s %s (shape #%d) into position %s, %s",
+		step.Number, b.Color, b.Name, p.Solution.ShapeIndex[step.BlockIdx], p.Solution.Shifts[step.BlockIdx], where)
+}
+
+// planner holds the state of the search for an assembly order
+type planner struct {
+	// cells occupied by each block
+	cells [][]vector.V
+
+	// candidates lists the block indices in the order they are tried
+	candidates []int
+
+	// top is the height of the volume
+	top int
+
+	// occupied contains the cells of the blocks placed so far
+	occupied map[vector.V]bool
+
+	// order contains the blocks placed so far
+	order []int
+
+	// failed contains the sets of placed blocks (bit i stands for block i) from which
+	// the remaining blocks can't be placed
+	failed map[uint64]bool
+}
+
+// search recursively tries to place the remaining blocks, placed is the set of blocks already placed
+func (p *planner) search(placed uint64) bool {
+	if len(p.order) == len(p.cells) {
+		return true
+	}
+	if p.failed[placed] {
+		return false
+	}
+
+	for _, blockIdx := range p.candidates {
+		if placed&(1<<blockIdx) != 0 || !p.canLower(blockIdx) {
+			continue
+		}
+
+		for _, c := range p.cells[blockIdx] {
+			p.occupied[c] = true
+		}
+		p.order = append(p.order, blockIdx)
+
+		if p.search(placed | 1<<blockIdx) {
+			return true
+		}
+
+		p.order = p.order[:len(p.order)-1]
+		for _, c := range p.cells[blockIdx] {
+			delete(p.occupied, c)
+		}
+	}
+
+	p.failed[placed] = true
+	return false
+}
+
+// canLower returns true if the block can be lowered vertically into its position without
+// colliding with the occupied cells, and comes to rest there because it is standing on
+// the ground or on an occupied cell
+func (p *planner) canLower(blockIdx int) bool {
+	supported := false
+	for _, c := range p.cells[blockIdx] {
+		for z := c[2] + 1; z < p.top; z++ {
+			if p.occupied[vector.V{c[0], c[1], z}] {
+				return false
+			}
+		}
+		if c[2] == 0 || p.occupied[vector.V{c[0], c[1], c[2] - 1}] {
+			supported = true
+		}
+	}
+	return supported
+}
+
+// blockCells returns the cells of the volume occupied by each block of the solution
+func blockCells(sol *gamesolution.S) [][]vector.V {
+	cells := make([][]vector.V, len(sol.Blocks))
+	for i, b := range sol.Blocks {
+		shape := b.Shapes[sol.ShapeIndex[i]]
+		shift := sol.Shifts[i]
+		for x := 0; x < shape.DimX; x++ {
+			for y := 0; y < shape.DimY; y++ {
+				for z := 0; z < shape.DimZ; z++ {
+					if shape.Get(x, y, z) == 1 {
+						cells[i] = append(cells[i], vector.V{x + shift[0], y + shift[1], z + shift[2]})
+					}
+				}
+			}
+		}
+	}
+	return cells
+}
+
+// lowest returns the smallest z-coordinate of the cells
+func lowest(cells []vector.V) int {
+	min := cells[0][2]
+	for _, c := range cells {
+		if c[2] < min {
+			min = c[2]
+		}
+	}
+	return min
+}
@@ -0,0 +1,92 @@
+package assembly_test
+
+import (
+	"testing"
+	. "ubongo/assembly"
+	"ubongo/base/vector"
+	"ubongo/block"
+	"ubongo/card"
+	"ubongo/cardfactory"
+	"ubongo/game"
+	"ubongo/gamesolution"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	for _, sol := range game.New(p).Solve() {
+		plan := New(sol)
+		assert.NotNil(t, plan)
+		assert.Equal(t, sol, plan.Solution)
+
+		// every block is placed exactly once
+		assert.Equal(t, len(sol.Blocks), len(plan.Order))
+		assert.ElementsMatch(t, []int{0, 1, 2, 3}, plan.Order)
+
+		// the first block must stand on the ground
+		first := plan.Order[0]
+		assert.Equal(t, 0, sol.Shifts[first][2])
+	}
+
+	assert.Panics(t, func() { New(nil) })
+}
+
+func TestNotBuildable(t *testing.T) {
+	// only the first of the 4 solutions of this problem can't be built: in every order
+	// a block is either blocked from above or has nothing to rest on
+	p := cardfactory.Get().Get(card.Difficult, 2).Problems[6]
+	solutions := game.New(p).Solve()
+	assert.Equal(t, 4, len(solutions))
+	assert.Nil(t, New(solutions[0]))
+	assert.False(t, IsBuildable(solutions[0]))
+	for _, sol := range solutions[1:] {
+		assert.True(t, IsBuildable(sol))
+	}
+
+	// the only solution of this problem can't be built
+	p = cardfactory.Get().Get(card.Difficult, 15).Problems[10]
+	solutions = game.New(p).Solve()
+	assert.Equal(t, 1, len(solutions))
+	assert.False(t, IsBuildable(solutions[0]))
+}
+
+func TestFloating(t *testing.T) {
+	// a single block on the second level has nothing to rest on
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	sol := game.New(p).Solve()[0]
+	b := sol.Blocks[0]
+	floating := gamesolution.New([]*block.B{b}, sol.ShapeIndex[:1], []vector.V{{0, 0, 1}})
+	assert.False(t, IsBuildable(floating))
+
+	onGround := gamesolution.New([]*block.B{b}, sol.ShapeIndex[:1], []vector.V{{0, 0, 0}})
+	assert.True(t, IsBuildable(onGround))
+}
+
+func TestSteps(t *testing.T) {
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	plan := New(game.New(p).Solve()[0])
+	steps := plan.Steps()
+
+	assert.Equal(t, len(plan.Order), len(steps))
+	for i, step := range steps {
+		assert.Equal(t, i+1, step.Number)
+		assert.Equal(t, plan.Order[i], step.BlockIdx)
+		assert.Equal(t, plan.Order[:i], step.Placed)
+		assert.Contains(t, plan.Describe(step), plan.Solution.Blocks[step.BlockIdx].Name)
+	}
+	assert.True(t, steps[0].OnGround)
+	assert.Contains(t, plan.Describe(steps[0]), "on the ground")
+
+	var nilPlan *P = nil
+	assert.Equal(t, 0, len(nilPlan.Steps()))
+}
+
+func TestString(t *testing.T) {
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	plan := New(game.New(p).Solve()[0])
+	assert.True(t, len(plan.String()) > 20)
+
+	var nilPlan *P = nil
+	assert.Equal(t, "(nil)", nilPlan.String())
+}
@@ -192,6 +192,47 @@ func (a *A) AllTrue(f func(x, y, z int, currentValue int8) bool) bool {
 	}
 }
 
+// Components returns the sizes of all connected regions of elements equal to value,
+// where two elements are connected if they share a face. The regions are listed in the
+// order of their first element. Applying this on a nil reference returns an empty slice
+func (a *A) Components(value int8) []int {
+	sizes := make([]int, 0)
+	if a == nil {
+		return sizes
+	}
+
+	visited := New(a.DimX, a.DimY, a.DimZ)
+	directions := [6][3]int{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}}
+	for x := 0; x < a.DimX; x++ {
+		for y := 0; y < a.DimY; y++ {
+			for z := 0; z < a.DimZ; z++ {
+				if a.Get(x, y, z) != value || visited.Get(x, y, z) == 1 {
+					continue
+				}
+				// flood-fill the region starting at x,y,z
+				size := 0
+				stack := [][3]int{{x, y, z}}
+				visited.Set(x, y, z, 1)
+				for len(stack) > 0 {
+					c := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					size++
+					for _, d := range directions {
+						nx, ny, nz := c[0]+d[0], c[1]+d[1], c[2]+d[2]
+						if nx >= 0 && ny >= 0 && nz >= 0 && nx < a.DimX && ny < a.DimY && nz < a.DimZ &&
+							a.Get(nx, ny, nz) == value && visited.Get(nx, ny, nz) == 0 {
+							visited.Set(nx, ny, nz, 1)
+							stack = append(stack, [3]int{nx, ny, nz})
+						}
+					}
+				}
+				sizes = append(sizes, size)
+			}
+		}
+	}
+	return sizes
+}
+
 // RotateZ rotates the array around the z-axis counter-clockwise by 90°
 func (a *A) RotateZ() *A {
 	if a == nil {
@@ -199,6 +199,19 @@ func TestAllTrueNil(t *testing.T) {
 	assert.False(t, a.AllTrue(nil))
 }
 
+func TestComponents(t *testing.T) {
+	// two regions of zeros: one of size 3 and a single element only touching diagonally
+	a := NewFromData([][][]int8{{{0, 0}, {1, 1}}, {{0, 1}, {1, 0}}})
+	assert.Equal(t, []int{3, 1}, a.Components(0))
+	assert.Equal(t, []int{3, 1}, a.Components(1))
+	assert.Equal(t, []int{}, a.Components(-1))
+}
+
+func TestComponentsNil(t *testing.T) {
+	var a *A = nil
+	assert.Equal(t, 0, len(a.Components(0)))
+}
+
 func TestRotateZ(t *testing.T) {
 	orig := NewFromData([][][]int8{{{0}, {3}}, {{1}, {4}}, {{2}, {5}}})
 	exp := NewFromData([][][]int8{{{3}, {4}, {5}}, {{0}, {1}, {2}}})
@@ -0,0 +1,91 @@
+// Package bitmask contains the type M, a fixed size set of bits used to
+// represent game volumes and block placements in a compact form
+package bitmask
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Size is the number of bits a mask can hold
+const Size = 128
+
+// M is a set of Size bits, where bit i represents the i-th unit cube of a volume.
+// Like vector.V it is a value type, all operations return a new mask
+type M [2]uint64
+
+// Zero represents the empty mask
+var Zero M = M{0, 0}
+
+// String returns the mask as binary string, the lowest bit is the rightmost
+func (m M) String() string {
+	return fmt.Sprintf("%064b%064b", m[1], m[0])
+}
+
+// Set returns a copy of m with bit i set
+// Panics if i is outside of 0..Size-1
+func (m M) Set(i int) M {
+	if i < 0 || i >= Size {
+		panic(fmt.Sprintf("Bit index %d is out of range 0..%d", i, Size-1))
+	}
+	m[i/64] |= 1 << (i % 64)
+	return m
+}
+
+// Clear returns a copy of m with bit i cleared
+// Panics if i is outside of 0..Size-1
+func (m M) Clear(i int) M {
+	if i < 0 || i >= Size {
+		panic(fmt.Sprintf("Bit index %d is out of range 0..%d", i, Size-1))
+	}
+	m[i/64] &^= 1 << (i % 64)
+	return m
+}
+
+// Get returns true if bit i is set. Returns false for indices outside of 0..Size-1
+func (m M) Get(i int) bool {
+	if i < 0 || i >= Size {
+		return false
+	}
+	return m[i/64]&(1<<(i%64)) != 0
+}
+
+// And returns the intersection of a and b
+func (a M) And(b M) M {
+	return M{a[0] & b[0], a[1] & b[1]}
+}
+
+// Or returns the union of a and b
+func (a M) Or(b M) M {
+	return M{a[0] | b[0], a[1] | b[1]}
+}
+
+// AndNot returns the bits of a that are not set in b
+func (a M) AndNot(b M) M {
+	return M{a[0] &^ b[0], a[1] &^ b[1]}
+}
+
+// Intersects returns true if a and b have at least one bit in common
+func (a M) Intersects(b M) bool {
+	return a[0]&b[0] != 0 || a[1]&b[1] != 0
+}
+
+// IsZero returns true if no bit is set
+func (m M) IsZero() bool {
+	return m[0] == 0 && m[1] == 0
+}
+
+// Count returns the number of bits set
+func (m M) Count() int {
+	return bits.OnesCount64(m[0]) + bits.OnesCount64(m[1])
+}
+
+// Lowest returns the index of the lowest bit set, or -1 if the mask is empty
+func (m M) Lowest() int {
+	if m[0] != 0 {
+		return bits.TrailingZeros64(m[0])
+	} else if m[1] != 0 {
+		return 64 + bits.TrailingZeros64(m[1])
+	}
+	return -1
+}
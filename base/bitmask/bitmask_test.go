@@ -0,0 +1,66 @@
+package bitmask_test
+
+import (
+	"strings"
+	"testing"
+	. "ubongo/base/bitmask"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZero(t *testing.T) {
+	assert.True(t, Zero.IsZero())
+	assert.Equal(t, 0, Zero.Count())
+	assert.Equal(t, -1, Zero.Lowest())
+}
+
+func TestString(t *testing.T) {
+	s := Zero.Set(0).Set(127).String()
+	assert.Equal(t, Size, len(s))
+	assert.True(t, strings.HasPrefix(s, "1"))
+	assert.True(t, strings.HasSuffix(s, "01"))
+}
+
+func TestSetGet(t *testing.T) {
+	m := Zero.Set(3).Set(64).Set(127)
+
+	assert.True(t, m.Get(3))
+	assert.True(t, m.Get(64))
+	assert.True(t, m.Get(127))
+	assert.False(t, m.Get(4))
+	assert.False(t, m.Get(-1))
+	assert.False(t, m.Get(Size))
+	assert.True(t, Zero.IsZero(), "Set must not modify the receiver")
+
+	assert.Panics(t, func() { Zero.Set(Size) })
+	assert.Panics(t, func() { Zero.Set(-1) })
+}
+
+func TestClear(t *testing.T) {
+	m := Zero.Set(3).Set(64)
+
+	assert.Equal(t, Zero.Set(64), m.Clear(3))
+	assert.Equal(t, Zero.Set(3), m.Clear(64))
+	assert.Equal(t, m, m.Clear(5))
+	assert.Panics(t, func() { Zero.Clear(Size) })
+}
+
+func TestOperators(t *testing.T) {
+	a := Zero.Set(1).Set(2).Set(70)
+	b := Zero.Set(2).Set(70).Set(100)
+
+	assert.Equal(t, Zero.Set(2).Set(70), a.And(b))
+	assert.Equal(t, Zero.Set(1).Set(2).Set(70).Set(100), a.Or(b))
+	assert.Equal(t, Zero.Set(1), a.AndNot(b))
+	assert.True(t, a.Intersects(b))
+	assert.False(t, a.Intersects(Zero.Set(100)))
+}
+
+func TestCount(t *testing.T) {
+	assert.Equal(t, 3, Zero.Set(0).Set(63).Set(64).Count())
+}
+
+func TestLowest(t *testing.T) {
+	assert.Equal(t, 5, Zero.Set(5).Set(90).Lowest())
+	assert.Equal(t, 90, Zero.Set(90).Set(100).Lowest())
+}
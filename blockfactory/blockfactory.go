@@ -180,25 +180,96 @@ func (f *F) GetAll() *blockset.S {
 //   - the number of blocks in each set is equal to blockCount
 //   - every block type is only used once
 //
+// The random numbers are taken from r, so the same seed yields the same blocksets.
+// If r is nil, a generator seeded with the current time is used.
+//
 // NOTE: the returned slice can be smaller than resultCount, as no duplicate
 // results are generated and the algormithms stops if it fails to generate new
 // results
-func (bf *F) GenerateBlocksets(volume, blockCount, resultCount int) []*blockset.S {
+func (bf *F) GenerateBlocksets(volume, blockCount, resultCount int, r *rand.Rand) []*blockset.S {
+	if bf == nil {
+		return []*blockset.S{}
+	}
+	return bf.generateBlocksets(volume, blockCount, resultCount, bf.singleCopies(), r)
+}
+
+// GenerateMultisets works like GenerateBlocksets, but returns multisets that can contain
+// a block type several times, up to the number of copies given in maxCopies
+// (map[BlockNumber]Count). Block types missing in maxCopies are not used
+func (bf *F) GenerateMultisets(volume, blockCount, resultCount int, maxCopies map[int]int, r *rand.Rand) []*blockset.S {
 	if bf == nil {
 		return []*blockset.S{}
 	}
+	return bf.generateBlocksets(volume, blockCount, resultCount, maxCopies, r)
+}
+
+// AllBlocksets returns all blocksets with blockCount blocks and a total volume of volume,
+// using every block type only once. Unlike GenerateBlocksets the result is complete and
+// always returned in the same order
+func (bf *F) AllBlocksets(volume, blockCount int) []*blockset.S {
+	if bf == nil {
+		return []*blockset.S{}
+	}
+	return bf.AllMultisets(volume, blockCount, bf.singleCopies())
+}
+
+// singleCopies returns the maximum number of copies (map[BlockNumber]Count) allowing
+// every block type once
+func (bf *F) singleCopies() map[int]int {
+	maxCopies := make(map[int]int)
+	for i := bf.MinBlockNumber; i <= bf.MaxBlockNumber; i++ {
+		maxCopies[i] = 1
+	}
+	return maxCopies
+}
+
+// AllMultisets works like AllBlocksets, but returns multisets that can contain a block type
+// several times, up to the number of copies given in maxCopies (map[BlockNumber]Count)
+func (bf *F) AllMultisets(volume, blockCount int, maxCopies map[int]int) []*blockset.S {
+	results := make([]*blockset.S, 0)
+	if bf == nil {
+		return results
+	}
 
+	// recursively add copies of the blocks in the order of their numbers
+	var add func(number, volume, blockCount int, blocks []*block.B)
+	add = func(number, volume, blockCount int, blocks []*block.B) {
+		if blockCount == 0 {
+			if volume == 0 {
+				results = append(results, blockset.NewMultiset(blocks...))
+			}
+			return
+		}
+		if number > bf.MaxBlockNumber || volume <= 0 {
+			return
+		}
+		b := bf.ByNumber(number)
+		for copies := 0; copies <= maxCopies[number] && copies <= blockCount && copies*b.Volume <= volume; copies++ {
+			add(number+1, volume-copies*b.Volume, blockCount-copies, blocks)
+			blocks = append(blocks, b)
+		}
+	}
+	add(bf.MinBlockNumber, volume, blockCount, make([]*block.B, 0, blockCount))
+
+	return results
+}
+
+// generateBlocksets is the implementation of GenerateBlocksets and GenerateMultisets
+func (bf *F) generateBlocksets(volume, blockCount, resultCount int, maxCopies map[int]int, r *rand.Rand) []*blockset.S {
 	// maximum number of tries to create a random blockset that is not already in
 	// the result
 	const maxTry = 10
 
 	// create all possible partitions that define how many blocks of a specific volume
 	// are used to fill the volume
-	// limit the 3-volume
-	max3 := bf.byVolume[3].Count
-	max4 := bf.byVolume[4].Count
-	max5 := bf.byVolume[5].Count
-	partitions := extmath.CreateParitions(volume, []int{3, 4, 5}, map[int]int{3: max3, 4: max4, 5: max5}, blockCount)
+	// limit the number of blocks per volume to the available copies
+	maxCounts := map[int]int{3: 0, 4: 0, 5: 0}
+	for vol := range maxCounts {
+		for _, b := range bf.byVolume[vol].AsSlice() {
+			maxCounts[vol] += maxCopies[b.Number]
+		}
+	}
+	partitions := extmath.CreateParitions(volume, []int{3, 4, 5}, maxCounts, blockCount)
 	partCount := len(partitions)
 
 	// abort if there are no partitions fulfilling the given criteria
@@ -206,7 +277,9 @@ func (bf *F) GenerateBlocksets(volume, blockCount, resultCount int) []*blockset.
 		return []*blockset.S{}
 	}
 
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	if r == nil {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
 
 	// generate resultCount results as requested
 	results := make([]*blockset.S, resultCount)
@@ -218,19 +291,22 @@ func (bf *F) GenerateBlocksets(volume, blockCount, resultCount int) []*blockset.
 			// randomly choose a partition
 			partition := partitions[r.Intn(partCount)]
 
-			// cycle through the volume-keys of the chosen partition
-			for vol, count := range partition {
-				// randomly choose count blocks of the given volume
+			// cycle through the volume-keys of the chosen partition, in a fixed order
+			// to draw the random numbers reproducibly
+			for _, vol := range []int{3, 4, 5} {
+				count := partition[vol]
+				// randomly choose count blocks of the given volume, as long as copies are left
 				for j := 0; j < count; j++ {
 					randIdx := -1
 					for {
 						randIdx = r.Intn(bf.byVolume[vol].Count)
-						if !curResult.Contains(bf.byVolume[vol].Get(randIdx).Number) {
+						number := bf.byVolume[vol].Get(randIdx).Number
+						if curResult.CountOf(number) < maxCopies[number] {
 							break
 						}
 					}
 					bl := bf.byVolume[vol].Get(randIdx)
-					curResult.Add(bl)
+					curResult.AddCopies(bl)
 				}
 			}
 			if !blockset.ContainsBlockset(results, curResult) {
@@ -1,9 +1,11 @@
 package blockfactory_test
 
 import (
+	"math/rand"
 	"testing"
 	"ubongo/block"
 	. "ubongo/blockfactory"
+	"ubongo/blockset"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -98,7 +100,7 @@ func TestGenerateBlocksets(t *testing.T) {
 	f := Get()
 	vol := 21
 	blockCount := 5
-	blocksets := f.GenerateBlocksets(vol, blockCount, 10000)
+	blocksets := f.GenerateBlocksets(vol, blockCount, 10000, nil)
 
 	// this test should return less than the requested number of results
 	assert.LessOrEqual(t, len(blocksets), 10000)
@@ -108,18 +110,102 @@ func TestGenerateBlocksets(t *testing.T) {
 	}
 
 	// this test should return exactly 3 results
-	blocksets = f.GenerateBlocksets(vol, blockCount, 3)
+	blocksets = f.GenerateBlocksets(vol, blockCount, 3, nil)
 	assert.LessOrEqual(t, len(blocksets), 3)
 
 	var nilFactory *F = nil
-	assert.Equal(t, 0, len(nilFactory.GenerateBlocksets(3, 4, 99)))
+	assert.Equal(t, 0, len(nilFactory.GenerateBlocksets(3, 4, 99, nil)))
+}
+
+func TestGenerateBlocksetsSeed(t *testing.T) {
+	f := Get()
+	expected := f.GenerateBlocksets(21, 5, 50, rand.New(rand.NewSource(42)))
+	actual := f.GenerateBlocksets(21, 5, 50, rand.New(rand.NewSource(42)))
+	assert.Equal(t, 50, len(expected))
+	for i := range expected {
+		assert.Equal(t, expected[i].String(), actual[i].String())
+	}
+
+	maxCopies := map[int]int{8: 4, 16: 2, 1: 2}
+	expected = f.GenerateMultisets(17, 5, 5, maxCopies, rand.New(rand.NewSource(7)))
+	actual = f.GenerateMultisets(17, 5, 5, maxCopies, rand.New(rand.NewSource(7)))
+	assert.Equal(t, len(expected), len(actual))
+	for i := range expected {
+		assert.Equal(t, expected[i].String(), actual[i].String())
+	}
+}
+
+func TestGenerateMultisets(t *testing.T) {
+	f := Get()
+	maxCopies := map[int]int{8: 4, 16: 2} // blue v (volume 3), green L (volume 4)
+
+	// three blue v are the only possibility for volume 9
+	blocksets := f.GenerateMultisets(9, 3, 5, maxCopies, nil)
+	assert.Equal(t, 1, len(blocksets))
+	assert.True(t, blocksets[0].Equals(blockset.NewMultiset(f.Blue_v, f.Blue_v, f.Blue_v)))
+
+	// three green L would be needed, but there are only two
+	assert.Equal(t, 0, len(f.GenerateMultisets(12, 3, 5, maxCopies, nil)))
+
+	blocksets = f.GenerateMultisets(17, 5, 100, maxCopies, nil)
+	assert.Less(t, 0, len(blocksets))
+	for _, s := range blocksets {
+		assert.Equal(t, 5, s.Count)
+		assert.Equal(t, 17, s.Volume())
+		for number, count := range s.Counts() {
+			assert.LessOrEqual(t, count, maxCopies[number])
+		}
+	}
+
+	var nilFactory *F = nil
+	assert.Equal(t, 0, len(nilFactory.GenerateMultisets(3, 4, 99, maxCopies, nil)))
+}
+
+func TestAllBlocksets(t *testing.T) {
+	f := Get()
+	blocksets := f.AllBlocksets(21, 5)
+	assert.Less(t, 0, len(blocksets))
+	for i, s := range blocksets {
+		assert.Equal(t, 5, s.Count)
+		assert.Equal(t, 21, s.Volume())
+		assert.False(t, s.IsMultiset())
+		assert.False(t, blockset.ContainsBlockset(blocksets[:i], s), "Duplicate blockset %s", s)
+	}
+
+	// all randomly generated sets must be part of the complete list
+	for _, s := range f.GenerateBlocksets(21, 5, 100, nil) {
+		assert.True(t, blockset.ContainsBlockset(blocksets, s))
+	}
+
+	assert.Equal(t, 0, len(f.AllBlocksets(18, 5)))
+
+	var nilFactory *F = nil
+	assert.Equal(t, 0, len(nilFactory.AllBlocksets(21, 5)))
+}
+
+func TestAllMultisets(t *testing.T) {
+	f := Get()
+	maxCopies := map[int]int{8: 4, 16: 2} // blue v (volume 3), green L (volume 4)
+
+	// 3 blue v and 2 green L are the only combination of 5 blocks with volume 17
+	blocksets := f.AllMultisets(17, 5, maxCopies)
+	assert.Equal(t, 1, len(blocksets))
+	assert.True(t, blocksets[0].Equals(blockset.NewMultiset(f.Blue_v, f.Blue_v, f.Blue_v, f.Green_L, f.Green_L)))
+
+	// volume 12 can be filled with 4 blue v, or with 3 green L only if there were enough
+	blocksets = f.AllMultisets(12, 4, maxCopies)
+	assert.Equal(t, 1, len(blocksets))
+	assert.Equal(t, 0, len(f.AllMultisets(12, 3, maxCopies)))
+
+	var nilFactory *F = nil
+	assert.Equal(t, 0, len(nilFactory.AllMultisets(17, 5, maxCopies)))
 }
 
 func TestGenerateBlocksetsEmpty(t *testing.T) {
 	f := Get()
 	vol := 18
 	blockCount := 5
-	blocksets := f.GenerateBlocksets(vol, blockCount, 100)
+	blocksets := f.GenerateBlocksets(vol, blockCount, 100, nil)
 
 	assert.Equal(t, 0, len(blocksets))
 }
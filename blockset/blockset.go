@@ -7,7 +7,8 @@ import (
 	"ubongo/block"
 )
 
-// S is a (unordered) set of blocks, without duplicates
+// S is a (unordered) set of blocks. Sets created with New don't contain duplicates,
+// multisets created with NewMultiset can contain a block several times
 type S struct {
 	items []*block.B
 	Count int
@@ -30,6 +31,14 @@ func New(blocks ...*block.B) *S {
 	return &bs
 }
 
+// NewMultiset creates a blockset from a list/slice of blocks, keeping duplicates
+func NewMultiset(blocks ...*block.B) *S {
+	bs := S{}
+	bs.items = make([]*block.B, 0)
+	bs.AddCopies(blocks...)
+	return &bs
+}
+
 // Get returns the block reference for the given index
 // returns nil if the index is invalid
 func (bs *S) Get(idx int) *block.B {
@@ -87,7 +96,22 @@ func (bs *S) Add(blocks ...*block.B) {
 	bs.normalize()
 }
 
-// Remove removes the block with the given blockNumber from the blockset
+// AddCopies adds blocks to the blockset, even if they already exist
+func (bs *S) AddCopies(blocks ...*block.B) {
+	if bs == nil || blocks == nil {
+		return
+	}
+	for _, b := range blocks {
+		if b != nil {
+			bs.items = append(bs.items, b)
+		}
+	}
+	bs.Count = len(bs.items)
+	bs.normalize()
+}
+
+// Remove removes the block with the given blockNumber from the blockset, all copies
+// of it in case of a multiset
 func (bs *S) Remove(blockNumber int) {
 	if bs == nil {
 		return
@@ -105,6 +129,19 @@ func (bs *S) Remove(blockNumber int) {
 	}
 }
 
+// RemoveOne removes a single copy of the block with the given blockNumber from the blockset
+func (bs *S) RemoveOne(blockNumber int) {
+	if bs == nil {
+		return
+	}
+	for idx := range bs.items {
+		if bs.items[idx].Number == blockNumber {
+			bs.RemoveAt(idx)
+			return
+		}
+	}
+}
+
 // RemoveAt removes a block from the set by its index
 func (bs *S) RemoveAt(idx int) {
 	if bs == nil || idx < 0 || idx >= bs.Count {
@@ -147,6 +184,45 @@ func (bs *S) Contains(blockNumber int) bool {
 	}
 }
 
+// CountOf returns the number of copies of the block with the given number in the set
+func (bs *S) CountOf(blockNumber int) int {
+	count := 0
+	if bs != nil {
+		for _, b := range bs.items {
+			if b.Number == blockNumber {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// Counts returns the number of copies of each block in the set
+// map[BlockNumber]Count
+func (bs *S) Counts() map[int]int {
+	counts := make(map[int]int)
+	if bs != nil {
+		for _, b := range bs.items {
+			counts[b.Number]++
+		}
+	}
+	return counts
+}
+
+// IsMultiset returns true if the set contains at least one block several times
+func (bs *S) IsMultiset() bool {
+	if bs == nil {
+		return false
+	}
+	// the items are sorted, so copies are adjacent
+	for i := 1; i < bs.Count; i++ {
+		if bs.items[i].Number == bs.items[i-1].Number {
+			return true
+		}
+	}
+	return false
+}
+
 // Clone creates a clone of the blockset, copying the block references,
 // not the blocks themselves
 func (orig *S) Clone() *S {
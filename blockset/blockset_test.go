@@ -20,6 +20,70 @@ func TestNew(t *testing.T) {
 	assert.Equal(t, 10, bs.Get(2).Number)
 }
 
+func TestNewMultiset(t *testing.T) {
+	f := blockfactory.Get()
+	bs := NewMultiset(f.ByNumber(8), f.ByNumber(2), f.ByNumber(8), nil)
+	assert.Equal(t, 3, bs.Count)
+	assert.Equal(t, 2, bs.Get(0).Number)
+	assert.Equal(t, 8, bs.Get(1).Number)
+	assert.Equal(t, 8, bs.Get(2).Number)
+	assert.True(t, bs.IsMultiset())
+	assert.False(t, New(f.ByNumber(8), f.ByNumber(8)).IsMultiset())
+
+	bs.AddCopies(f.ByNumber(2))
+	assert.Equal(t, 4, bs.Count)
+	assert.Equal(t, 2, bs.Get(1).Number)
+
+	var nilBs *S = nil
+	assert.False(t, nilBs.IsMultiset())
+	assert.NotPanics(t, func() { nilBs.AddCopies(f.ByNumber(1)) })
+}
+
+func TestCountOf(t *testing.T) {
+	f := blockfactory.Get()
+	bs := NewMultiset(f.ByNumber(8), f.ByNumber(2), f.ByNumber(8), f.ByNumber(8))
+
+	assert.Equal(t, 3, bs.CountOf(8))
+	assert.Equal(t, 1, bs.CountOf(2))
+	assert.Equal(t, 0, bs.CountOf(5))
+	assert.Equal(t, map[int]int{2: 1, 8: 3}, bs.Counts())
+	assert.Equal(t, 3*f.ByNumber(8).Volume+f.ByNumber(2).Volume, bs.Volume())
+
+	var nilBs *S = nil
+	assert.Equal(t, 0, nilBs.CountOf(8))
+	assert.Equal(t, 0, len(nilBs.Counts()))
+}
+
+func TestRemoveOne(t *testing.T) {
+	f := blockfactory.Get()
+	bs := NewMultiset(f.ByNumber(8), f.ByNumber(2), f.ByNumber(8))
+
+	bs.RemoveOne(8)
+	assert.Equal(t, 2, bs.Count)
+	assert.Equal(t, 1, bs.CountOf(8))
+
+	bs.RemoveOne(5) // remove non-existing
+	assert.Equal(t, 2, bs.Count)
+
+	bs.AddCopies(f.ByNumber(8))
+	bs.Remove(8) // removes all copies
+	assert.Equal(t, 1, bs.Count)
+	assert.Equal(t, 2, bs.Get(0).Number)
+
+	var nilBs *S = nil
+	assert.NotPanics(t, func() { nilBs.RemoveOne(99) })
+}
+
+func TestMultisetEquals(t *testing.T) {
+	f := blockfactory.Get()
+	bs := NewMultiset(f.ByNumber(8), f.ByNumber(2), f.ByNumber(8))
+
+	assert.True(t, bs.Equals(NewMultiset(f.ByNumber(8), f.ByNumber(8), f.ByNumber(2))))
+	assert.True(t, bs.Equals(bs.Clone()))
+	assert.False(t, bs.Equals(NewMultiset(f.ByNumber(2), f.ByNumber(2), f.ByNumber(8))))
+	assert.False(t, bs.Equals(New(f.ByNumber(8), f.ByNumber(2), f.ByNumber(8))))
+}
+
 func TestGet(t *testing.T) {
 	f := blockfactory.Get()
 	bs := New(f.ByNumber(7), f.ByNumber(5), f.ByNumber(1))
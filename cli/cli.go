@@ -3,16 +3,21 @@ package cli
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
 	"time"
+	"ubongo/assembly"
 	"ubongo/blockfactory"
 	"ubongo/card"
 	"ubongo/cardfactory"
 	"ubongo/game"
+	"ubongo/gamesolution"
 	"ubongo/graphics"
+	"ubongo/inventory"
 )
 
 // Cli represents the command line interface
@@ -30,6 +35,9 @@ type MenuEntry struct {
 	Action MenuOptionFunc
 }
 
+// maxSolveDuration limits the time the CLI waits for the solver of a single problem
+const maxSolveDuration = time.Minute
+
 // MenuOptionFunc is a parameter-less function representing a menu option
 type MenuOptionFunc func(cli *Cli)
 
@@ -41,6 +49,7 @@ func New() *Cli {
 		{"2", "Calculate solution statistics", menuOptionCalcSolutionStatistics},
 		{"3", "Generate insane problems", menuOptionGenerateInsaneProblems},
 		{"4", "Visualize a solution", menuOptionVisualizeSolution},
+		{"5", "Create assembly instructions", menuOptionCreateInstructions},
 		{"0", "Quit", menuOptionQuit},
 	}
 	return cli
@@ -135,6 +144,68 @@ func (cli *Cli) readProblem(cf *cardfactory.F) (int, card.UbongoDifficulty, int)
 	return 0, card.Easy, 0
 }
 
+// readSeed reads the seed for the random numbers from the command line,
+// an empty input selects a seed based on the current time
+func (cli *Cli) readSeed() int64 {
+	reader := bufio.NewReader(os.Stdin)
+	replacer := strings.NewReplacer("\n", "", "\r", "")
+	for {
+		fmt.Print("Enter seed (empty for random): ")
+
+		input, readErr := reader.ReadString('\n')
+
+		if readErr == nil {
+			input = replacer.Replace(input)
+			if input == "" {
+				return time.Now().UnixNano()
+			}
+			seed, err := strconv.ParseInt(input, 10, 64)
+			if err == nil {
+				return seed
+			} else {
+				fmt.Println("Invalid seed, must be an integer number")
+			}
+		}
+
+		// abort loop if termination was signalled
+		if cli.doQuitFlag {
+			break
+		}
+	}
+	return time.Now().UnixNano()
+}
+
+// readPlayers reads the number of players (1..4) from the command line,
+// an empty input selects 4 players
+func (cli *Cli) readPlayers() int {
+	reader := bufio.NewReader(os.Stdin)
+	replacer := strings.NewReplacer("\n", "", "\r", "")
+	for {
+		fmt.Print("Enter number of players (empty for 4): ")
+
+		input, readErr := reader.ReadString('\n')
+
+		if readErr == nil {
+			input = replacer.Replace(input)
+			if input == "" {
+				return 4
+			}
+			players, err := strconv.Atoi(input)
+			if err == nil && players >= 1 && players <= 4 {
+				return players
+			} else {
+				fmt.Println("Invalid number of players, must be 1..4")
+			}
+		}
+
+		// abort loop if termination was signalled
+		if cli.doQuitFlag {
+			break
+		}
+	}
+	return 4
+}
+
 // Run is the main routine of the command line interface and runs in a loop until terminated
 func (cli *Cli) Run() {
 	for {
@@ -183,7 +254,7 @@ func menuOptionCalcSolutionStatistics(cli *Cli) {
 	statsFile := "./results/solutions.csv"
 
 	cf := cardfactory.Get()
-	game.CreateSolutionStatistics(cf, statsFile)
+	game.CreateSolutionStatisticsParallel(cf, statsFile, 0)
 
 	fmt.Printf("Calculated solution statics and stored these in file %s\n", statsFile)
 }
@@ -193,8 +264,36 @@ func menuOptionGenerateInsaneProblems(cli *Cli) {
 	sourceDifficulty := card.Easy
 	height := 3
 	blockCount := 5
+	checkpointFile := "./results/cards/checkpoint.jsonl"
+	inventoryFile := "./results/inventory.txt"
+
+	// the blocks available are read from the inventory file, if it exists
+	inv := inventory.Ubongo()
+	if _, err := os.Stat(inventoryFile); err == nil {
+		if inv, err = inventory.Load(inventoryFile); err != nil {
+			fmt.Printf("Error reading inventory file %s: %s, aborted\n", inventoryFile, err)
+			return
+		}
+		fmt.Printf("Using the %d blocks of inventory file %s\n", inv.Total(), inventoryFile)
+	}
+
+	// resume an interrupted generation with the seed of its checkpoint
+	checkpoint, err := game.OpenCheckpoint(checkpointFile)
+	if err != nil {
+		fmt.Printf("Error opening checkpoint file %s: %s, aborted\n", checkpointFile, err)
+		return
+	}
+	seed, resume := checkpoint.Seed()
+	players, _ := checkpoint.Players()
+	if resume {
+		fmt.Printf("Resuming generation from checkpoint %s with %d completed dice numbers\n", checkpointFile, checkpoint.Len())
+	} else {
+		seed = cli.readSeed()
+		players = cli.readPlayers()
+	}
 
-	fmt.Printf("Generating problems with height %d and %d blocks based on layouts of %s cards\n", height, blockCount, sourceDifficulty)
+	fmt.Printf("Generating problems with height %d and %d blocks for %d players based on layouts of %s cards, seed %d\n",
+		height, blockCount, players, sourceDifficulty, seed)
 
 	t := time.Now()
 	resultFile := fmt.Sprintf("./results/cards/%s_%s-%02d%02d%02d.txt", targetDifficulty, t.Format("20060102"), t.Hour(), t.Minute(), t.Second())
@@ -202,17 +301,36 @@ func menuOptionGenerateInsaneProblems(cli *Cli) {
 	bf := blockfactory.Get()
 	cf := cardfactory.Get()
 
+	// Ctrl-C aborts the generation, the completed dice numbers are kept in the checkpoint
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fmt.Println("Press Ctrl-C to abort")
+
 	// generate card-set for each animal
 	cards := make([]*card.C, 0)
 	for _, animal := range card.AllAnimals() {
-		newCards := game.GenerateCardSet(cf, bf, animal, sourceDifficulty, targetDifficulty, height, blockCount, "")
+		opts := game.CardSetOptions{Seed: seed, Checkpoint: checkpoint, Progress: newProgressDisplay(), Inventory: inv, Players: players}
+		newCards, unfilled, err := game.GenerateCardSet(ctx, cf, bf, animal, sourceDifficulty, targetDifficulty, height, blockCount, opts, "")
+		fmt.Print("\r\033[K") // clear the progress line
+		if err != nil {
+			fmt.Printf("Generation aborted, run again to resume from checkpoint %s\n", checkpointFile)
+			checkpoint.Close()
+			return
+		}
 		cards = append(cards, newCards...)
 		newProbCount := 0
 		for _, c := range newCards {
 			newProbCount += len(c.Problems)
 		}
 		fmt.Printf("Created %d cards with %d problems for animal %s\n", len(newCards), newProbCount, animal)
+		for _, u := range unfilled {
+			fmt.Printf("  %s\n", u)
+		}
+	}
+	if err := checkpoint.Err(); err != nil {
+		fmt.Printf("Error writing checkpoint file %s: %s\n", checkpointFile, err)
 	}
+	checkpoint.Close()
 
 	// write all to one file
 	totalProblems := 0
@@ -221,31 +339,118 @@ func menuOptionGenerateInsaneProblems(cli *Cli) {
 		fmt.Printf("Error opening file %s for writing, aborted\n", resultFile)
 	} else {
 		defer f.Close()
+		f.WriteString(game.SeedHeader(seed, inv, players))
 		for _, c := range cards {
 			f.WriteString(c.VerbousString())
 			totalProblems += len(c.Problems)
 		}
+
+		// the generation is complete, the next one starts from scratch
+		os.Remove(checkpointFile)
+		fmt.Printf("Generated %d cards with %d problems with seed %d and saved to %s\n", len(cards), totalProblems, seed, resultFile)
 	}
+}
 
-	fmt.Printf("Generated %d cards with %d problems and saved to %s\n", len(cards), totalProblems, resultFile)
+// progressInterval is the minimum time between two updates of the progress display
+const progressInterval = 200 * time.Millisecond
+
+// newProgressDisplay returns a progress callback for GenerateCardSet that shows the progress
+// of an animal in a single console line
+func newProgressDisplay() func(game.CardSetProgress) {
+	type key struct{ cardNumber, diceNumber int }
+	reports := make(map[key]game.GenerateReport)
+	done := 0
+	var lastUpdate time.Time
+
+	return func(p game.CardSetProgress) {
+		reports[key{p.CardNumber, p.DiceNumber}] = p.Report
+		if p.Done {
+			done++
+		} else if time.Since(lastUpdate) < progressInterval {
+			return
+		}
+		lastUpdate = time.Now()
+
+		candidates, solvable := 0, 0
+		for _, r := range reports {
+			candidates += r.Candidates
+			solvable += r.Accepted
+		}
+		fmt.Printf("\r\033[K%s: %d card/dice combinations done, %d candidates tested, %d solvable, %s elapsed",
+			p.Animal, done, candidates, solvable, p.Elapsed.Round(time.Second))
+	}
 }
 
 func menuOptionVisualizeSolution(cli *Cli) {
-	solutionNumber := 0
-
 	cf := cardfactory.Get()
 	cardNumber, difficulty, diceNumber := cli.readProblem(cf)
 
-	sols := game.New(cf.Get(difficulty, cardNumber).Problems[diceNumber]).Solve()
-	gs := sols[solutionNumber]
+	// only the first solution is needed, stop the solver as soon as it is found
+	var gs *gamesolution.S
+	g := game.New(cf.Get(difficulty, cardNumber).Problems[diceNumber])
+	solutions, status := g.SolutionsWithStatus(context.Background(), game.SolveOptions{MaxDuration: maxSolveDuration})
+	for sol := range solutions {
+		gs = sol
+		break
+	}
+	if gs == nil {
+		if status() != game.Complete {
+			// the time limit was hit, there may be a solution nevertheless
+			fmt.Printf("Search %s without a solution for %s problem on card %d, dice %d\n",
+				strings.ToLower(status().String()), difficulty, cardNumber, diceNumber)
+		} else {
+			fmt.Printf("No solution found for %s problem on card %d, dice %d\n", difficulty, cardNumber, diceNumber)
+		}
+		return
+	}
 
-	fmt.Printf("Showing solution of %s problem on card %d, dice %d (solution %d out of %d)\n",
-		difficulty, cardNumber, diceNumber, solutionNumber+1, len(sols))
+	fmt.Printf("Showing first solution of %s problem on card %d, dice %d\n", difficulty, cardNumber, diceNumber)
 
 	graphics.Visualize(gs, 800, 600)
 	cli.doQuitFlag = true // we cannot continue with the cli because the Fyne-App is not reusalbe once closed
 }
 
+func menuOptionCreateInstructions(cli *Cli) {
+	instructionRenderResX := 800
+	instructionRenderResY := 600
+
+	cf := cardfactory.Get()
+	cardNumber, difficulty, diceNumber := cli.readProblem(cf)
+	targetPath := fmt.Sprintf("./results/instructions/%s_%02d_%02d", difficulty, cardNumber, diceNumber)
+
+	// use the first solution that can be built physically
+	var plan *assembly.P
+	g := game.New(cf.Get(difficulty, cardNumber).Problems[diceNumber])
+	solutions, status := g.SolutionsWithStatus(context.Background(), game.SolveOptions{MaxDuration: maxSolveDuration})
+	for sol := range solutions {
+		if plan = assembly.New(sol); plan != nil {
+			break
+		}
+	}
+	if plan == nil {
+		if status() != game.Complete {
+			// the time limit was hit, there may be a buildable solution nevertheless
+			fmt.Printf("Search %s without a buildable solution for %s problem on card %d, dice %d\n",
+				strings.ToLower(status().String()), difficulty, cardNumber, diceNumber)
+		} else {
+			fmt.Printf("No buildable solution found for %s problem on card %d, dice %d\n", difficulty, cardNumber, diceNumber)
+		}
+		return
+	}
+
+	for _, step := range plan.Steps() {
+		fmt.Println(plan.Describe(step))
+	}
+
+	if err := os.MkdirAll(targetPath, 0755); err != nil {
+		fmt.Printf("Error creating path %s, aborted\n", targetPath)
+		return
+	}
+	files := graphics.RenderPlan(plan, targetPath, instructionRenderResX, instructionRenderResY)
+
+	fmt.Printf("Rendered %d steps to path %s at resolution %dx%d\n", len(files), targetPath, instructionRenderResX, instructionRenderResY)
+}
+
 func menuOptionQuit(cli *Cli) {
 	cli.doQuitFlag = true
 }
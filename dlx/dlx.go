@@ -0,0 +1,332 @@
+// Package dlx implements a solver for Ubongo problems based on Knuth's
+// Dancing Links (Algorithm X). A problem is an exact-cover problem: every empty
+// cell of the volume must be covered exactly once and every block must be used once.
+// Identical blocks of a multiset share a block column that is covered when all copies are used
+package dlx
+
+import (
+	"fmt"
+	"sort"
+
+	"ubongo/base/vector"
+	"ubongo/block"
+	"ubongo/gamesolution"
+	"ubongo/placement"
+	"ubongo/problem"
+)
+
+// node is an element of the sparse cover matrix. Headers and row elements are
+// both nodes, the links are indices into M.nodes
+type node struct {
+	left, right, up, down int
+
+	// col is the index of the column header node
+	col int
+
+	// row is the index into M.rows, -1 for header nodes
+	row int
+}
+
+// row describes the block placement a row of the cover matrix represents
+type row struct {
+	// blockIdx is the index of the first copy of the block in the blockset
+	blockIdx int
+	shapeIdx int
+	shift    vector.V
+}
+
+// M is the exact-cover matrix of a problem, with one column per empty cell
+// of the volume plus one column per block number, and one row per block placement
+type M struct {
+	// nodes contains all nodes, index 0 is the root, followed by the column headers
+	nodes []node
+
+	// size contains the number of rows in each column, indexed by the header node
+	size []int
+
+	// copies contains the number of copies of the block for each block column, and
+	// used the number of copies placed so far, indexed by the header node
+	copies []int
+	used   []int
+
+	// rows contains the placement information for each row
+	rows []row
+
+	// blocks of the problem, in the order of the blockset
+	blocks []*block.B
+
+	// CellCount is the number of columns representing cells
+	CellCount int
+
+	// BlockCount is the number of columns representing blocks, one per block number
+	BlockCount int
+
+	// RowCount is the number of rows (block placements) of the matrix
+	RowCount int
+}
+
+// root is the index of the root node in M.nodes
+const root = 0
+
+// String returns a string representation of the matrix
+func (m *M) String() string {
+	if m == nil {
+		return "(nil)"
+	} else {
+		return fmt.Sprintf("DLX matrix (%d cell columns, %d block columns, %d rows)", m.CellCount, m.BlockCount, m.RowCount)
+	}
+}
+
+// New creates the exact-cover matrix for the given problem
+func New(p *problem.P) *M {
+	if p == nil {
+		panic("Problem must not be nil")
+	}
+
+	m := new(M)
+	m.blocks = p.Blocks.AsSlice()
+
+	// assign a column to each block number, copies share the column of the first one
+	blockCol := make(map[int]int)
+	firstCopy := make(map[int]int)
+	for blockIdx, b := range m.blocks {
+		if _, ok := firstCopy[b.Number]; !ok {
+			firstCopy[b.Number] = blockIdx
+			m.BlockCount++
+			blockCol[b.Number] = m.BlockCount
+		}
+	}
+
+	// assign a column to each empty cell of the volume, the cells are indexed like the ones
+	// of a placement table
+	volume := p.Shape.Extrude(p.Height)
+	box := volume.GetBoundingBox()
+	index := func(x, y, z int) int {
+		return (x*box[1]+y)*box[2] + z
+	}
+	cellCol := make(map[int]int)
+	for x := 0; x < volume.DimX; x++ {
+		for y := 0; y < volume.DimY; y++ {
+			for z := 0; z < volume.DimZ; z++ {
+				if volume.Get(x, y, z) == 0 {
+					m.CellCount++
+					cellCol[index(x, y, z)] = m.CellCount
+				}
+			}
+		}
+	}
+	for number := range blockCol {
+		blockCol[number] += m.CellCount
+	}
+
+	// create root and the column headers. Only the cell columns are linked into the circular
+	// list of the root, so the search branches on cells only and never on one of several copies.
+	// The block columns are linked to themselves
+	colCount := m.CellCount + m.BlockCount
+	m.nodes = make([]node, colCount+1)
+	m.size = make([]int, colCount+1)
+	m.copies = make([]int, colCount+1)
+	m.used = make([]int, colCount+1)
+	for i := range m.nodes {
+		m.nodes[i] = node{left: i - 1, right: i + 1, up: i, down: i, col: i, row: -1}
+		if i > m.CellCount {
+			m.nodes[i].left, m.nodes[i].right = i, i
+		}
+	}
+	m.nodes[root].left = m.CellCount
+	m.nodes[m.CellCount].right = root
+
+	// add one row per block placement. The placements are taken from the placement table,
+	// or enumerated directly if the volume is too big for a table
+	for number, col := range blockCol {
+		m.copies[col] = p.Blocks.CountOf(number)
+	}
+	var t *placement.T
+	if placement.Fits(p.Shape, p.Height) {
+		t = placement.Get(p.Shape, p.Height)
+	}
+	for blockIdx, b := range m.blocks {
+		if firstCopy[b.Number] != blockIdx {
+			continue
+		}
+		if t != nil {
+			for _, pl := range t.ForBlock(b) {
+				cols := make([]int, 0, b.Volume+1)
+				for cells := pl.Mask; !cells.IsZero(); {
+					cell := cells.Lowest()
+					cells = cells.Clear(cell)
+					cols = append(cols, cellCol[cell])
+				}
+				m.rows = append(m.rows, row{blockIdx, pl.ShapeIndex, pl.Shift})
+				m.addRow(len(m.rows)-1, append(cols, blockCol[b.Number]))
+			}
+			continue
+		}
+		for shapeIdx, shape := range b.Shapes {
+			for _, shift := range box.GetShiftVectors(shape.GetBoundingBox()) {
+				cols := make([]int, 0, b.Volume+1)
+				fits := true
+				for x := 0; x < shape.DimX && fits; x++ {
+					for y := 0; y < shape.DimY && fits; y++ {
+						for z := 0; z < shape.DimZ && fits; z++ {
+							if shape.Get(x, y, z) == 1 {
+								col, ok := cellCol[index(x+shift[0], y+shift[1], z+shift[2])]
+								cols = append(cols, col)
+								fits = ok
+							}
+						}
+					}
+				}
+				if fits {
+					m.rows = append(m.rows, row{blockIdx, shapeIdx, shift})
+					m.addRow(len(m.rows)-1, append(cols, blockCol[b.Number]))
+				}
+			}
+		}
+	}
+	m.RowCount = len(m.rows)
+
+	return m
+}
+
+// addRow appends a row with nodes in the given columns to the matrix
+func (m *M) addRow(rowIdx int, cols []int) {
+	first := len(m.nodes)
+	for i, col := range cols {
+		idx := len(m.nodes)
+		n := node{left: idx - 1, right: idx + 1, up: m.nodes[col].up, down: col, col: col, row: rowIdx}
+		if i == 0 {
+			n.left = first + len(cols) - 1
+		}
+		if i == len(cols)-1 {
+			n.right = first
+		}
+		m.nodes = append(m.nodes, n)
+		m.nodes[m.nodes[col].up].down = idx
+		m.nodes[col].up = idx
+		m.size[col]++
+	}
+}
+
+// cover removes column col and all rows intersecting it from the matrix
+func (m *M) cover(col int) {
+	nodes := m.nodes
+	nodes[nodes[col].right].left = nodes[col].left
+	nodes[nodes[col].left].right = nodes[col].right
+	for i := nodes[col].down; i != col; i = nodes[i].down {
+		for j := nodes[i].right; j != i; j = nodes[j].right {
+			nodes[nodes[j].down].up = nodes[j].up
+			nodes[nodes[j].up].down = nodes[j].down
+			m.size[nodes[j].col]--
+		}
+	}
+}
+
+// uncover reverts the operation of cover
+func (m *M) uncover(col int) {
+	nodes := m.nodes
+	for i := nodes[col].up; i != col; i = nodes[i].up {
+		for j := nodes[i].left; j != i; j = nodes[j].left {
+			m.size[nodes[j].col]++
+			nodes[nodes[j].down].up = j
+			nodes[nodes[j].up].down = j
+		}
+	}
+	nodes[nodes[col].right].left = col
+	nodes[nodes[col].left].right = col
+}
+
+// use places one copy of the block of column col, the column is covered when no copies are left
+func (m *M) use(col int) {
+	m.used[col]++
+	if m.used[col] == m.copies[col] {
+		m.cover(col)
+	}
+}
+
+// unuse reverts the operation of use
+func (m *M) unuse(col int) {
+	if m.used[col] == m.copies[col] {
+		m.uncover(col)
+	}
+	m.used[col]--
+}
+
+// Solve finds all solutions of the problem the matrix was created from
+func (m *M) Solve() []*gamesolution.S {
+	solutions := make([]*gamesolution.S, 0)
+	if m == nil {
+		return solutions
+	}
+
+	chosen := make([]int, 0, len(m.blocks))
+	m.search(&chosen, &solutions)
+
+	return solutions
+}
+
+// search is the recursive Algorithm X, called by Solve, don't call directly
+func (m *M) search(chosen *[]int, solutions *[]*gamesolution.S) {
+	nodes := m.nodes
+	if nodes[root].right == root {
+		// all cells are covered, it's a solution if all blocks were used
+		if len(*chosen) == len(m.blocks) {
+			*solutions = append(*solutions, m.solution(*chosen))
+		}
+		return
+	}
+
+	// choose the column with the fewest rows
+	col := nodes[root].right
+	for c := nodes[col].right; c != root; c = nodes[c].right {
+		if m.size[c] < m.size[col] {
+			col = c
+		}
+	}
+	if m.size[col] == 0 {
+		return
+	}
+
+	m.cover(col)
+	for r := nodes[col].down; r != col; r = nodes[r].down {
+		*chosen = append(*chosen, nodes[r].row)
+		for j := nodes[r].right; j != r; j = nodes[j].right {
+			if nodes[j].col > m.CellCount {
+				m.use(nodes[j].col)
+			} else {
+				m.cover(nodes[j].col)
+			}
+		}
+
+		m.search(chosen, solutions)
+
+		for j := nodes[r].left; j != r; j = nodes[j].left {
+			if nodes[j].col > m.CellCount {
+				m.unuse(nodes[j].col)
+			} else {
+				m.uncover(nodes[j].col)
+			}
+		}
+		*chosen = (*chosen)[:len(*chosen)-1]
+	}
+	m.uncover(col)
+}
+
+// solution converts the chosen rows into a game solution, ordered like the blockset.
+// Copies of a block are assigned in the order of their rows, like the solvers of the game package do
+func (m *M) solution(chosen []int) *gamesolution.S {
+	rows := make([]int, len(chosen))
+	copy(rows, chosen)
+	sort.Ints(rows)
+
+	shapeIndices := make([]int, len(m.blocks))
+	shifts := make([]vector.V, len(m.blocks))
+	next := make(map[int]int) // next copy to assign, by index of the first copy
+	for _, r := range rows {
+		blockIdx := m.rows[r].blockIdx + next[m.rows[r].blockIdx]
+		next[m.rows[r].blockIdx]++
+		shapeIndices[blockIdx] = m.rows[r].shapeIdx
+		shifts[blockIdx] = m.rows[r].shift
+	}
+	return gamesolution.New(m.blocks, shapeIndices, shifts)
+}
@@ -0,0 +1,142 @@
+package dlx_test
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"ubongo/base/array2d"
+	"ubongo/base/array3d"
+	"ubongo/block"
+	"ubongo/blockfactory"
+	"ubongo/blockset"
+	"ubongo/card"
+	"ubongo/cardfactory"
+	. "ubongo/dlx"
+	"ubongo/game"
+	"ubongo/gamesolution"
+	"ubongo/problem"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// solutionKey creates a string uniquely identifying a solution
+func solutionKey(s *gamesolution.S) string {
+	return fmt.Sprint(s.ShapeIndex, s.Shifts)
+}
+
+func TestNew(t *testing.T) {
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	m := New(p)
+
+	assert.Equal(t, p.Area*p.Height, m.CellCount)
+	assert.Equal(t, p.Blocks.Count, m.BlockCount)
+	assert.Less(t, 0, m.RowCount)
+
+	assert.Panics(t, func() { New(nil) })
+}
+
+// plate creates a custom block consisting of a single layer of dimX x dimY cubes
+func plate(number, dimX, dimY int) *block.B {
+	base := array3d.New(dimX, dimY, 1)
+	for x := 0; x < dimX; x++ {
+		for y := 0; y < dimY; y++ {
+			base.Set(x, y, 0, 1)
+		}
+	}
+	return &block.B{Number: number, Name: "plate", Shapes: base.CreateRotations(), Volume: dimX * dimY}
+}
+
+func TestSolveCustomBlocks(t *testing.T) {
+	// blocks not known by the block factory
+	p := problem.New(array2d.New(3, 2), 2, blockset.New(plate(20, 3, 2), plate(21, 3, 2)))
+	expected := game.New(p).Solve()
+	assert.Less(t, 0, len(expected))
+	assert.Equal(t, len(expected), len(New(p).Solve()))
+
+	// a volume too big for a placement table
+	p = problem.New(array2d.New(9, 9), 2, blockset.New(plate(20, 9, 9), plate(21, 9, 9)))
+	expected = game.New(p).Solve()
+	assert.Equal(t, 2, len(expected))
+	assert.Equal(t, len(expected), len(New(p).Solve()))
+}
+
+func TestString(t *testing.T) {
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	assert.True(t, len(New(p).String()) > 10)
+
+	var nilM *M = nil
+	assert.Equal(t, "(nil)", nilM.String())
+}
+
+func TestSolve(t *testing.T) {
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	solutions := New(p).Solve()
+
+	assert.Equal(t, 6, len(solutions))
+
+	var nilM *M = nil
+	assert.Equal(t, 0, len(nilM.Solve()))
+}
+
+func TestSolveNoSolution(t *testing.T) {
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1].Clone()
+	p.Blocks.RemoveAt(3)
+
+	assert.Equal(t, 0, len(New(p).Solve()))
+}
+
+func TestSolveMultiset(t *testing.T) {
+	f := blockfactory.Get()
+	p := problem.New(array2d.New(3, 2), 2, blockset.NewMultiset(f.Blue_v, f.Blue_v, f.Blue_v, f.Blue_v))
+	m := New(p)
+	assert.Equal(t, 1, m.BlockCount)
+
+	expected := game.New(p).Solve()
+	actual := m.Solve()
+	assert.Equal(t, 44, len(actual))
+	assert.ElementsMatch(t, expected, actual)
+}
+
+// TestSolveMatchesGame cross-checks the solutions with the solver of the game package
+func TestSolveMatchesGame(t *testing.T) {
+	f := cardfactory.Get()
+	for _, difficulty := range []card.UbongoDifficulty{card.Easy, card.Difficult} {
+		for _, p := range f.GetAllProblems(difficulty) {
+			expected := make(map[string]bool)
+			for _, s := range game.New(p).Solve() {
+				expected[solutionKey(s)] = true
+			}
+
+			actual := New(p).Solve()
+			assert.Equal(t, len(expected), len(actual), "Solution count differs for problem %s", p)
+			for _, s := range actual {
+				assert.True(t, expected[solutionKey(s)], "Unexpected solution %s", s)
+			}
+		}
+	}
+}
+
+// TestSolveMatchesStatistics cross-checks the solution counts in results/solutions.csv
+func TestSolveMatchesStatistics(t *testing.T) {
+	file, err := os.Open("../results/solutions.csv")
+	if err != nil {
+		t.Skipf("Solution statistics not available: %v", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	assert.Nil(t, err)
+
+	f := cardfactory.Get()
+	for _, rec := range records[1:] {
+		difficulty, _ := card.ParseDifficulty(rec[0])
+		cardNumber, _ := strconv.Atoi(rec[2])
+		diceNumber, _ := strconv.Atoi(rec[3])
+		solutionCount, _ := strconv.Atoi(rec[6])
+
+		p := f.Get(difficulty, cardNumber).Problems[diceNumber]
+		assert.Equal(t, solutionCount, len(New(p).Solve()), "Solution count differs for %s card %d dice %d", difficulty, cardNumber, diceNumber)
+	}
+}
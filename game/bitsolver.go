@@ -0,0 +1,145 @@
+package game
+
+import (
+	"sort"
+
+	"ubongo/base/bitmask"
+	"ubongo/base/vector"
+	"ubongo/gamesolution"
+	"ubongo/placement"
+)
+
+// fitsBitmask returns true if the volume of the game is small enough for the bitmask
+// representation used by the BitmaskSolver and the MostConstrainedCell strategy
+func (g *G) fitsBitmask() bool {
+	return placement.Fits(g.Shape, g.Volume.DimZ)
+}
+
+// fastestSolver returns the BitmaskSolver if the volume of the game fits into a bitmask,
+// the ArraySolver otherwise
+func (g *G) fastestSolver() SolverType {
+	if g.fitsBitmask() {
+		return BitmaskSolver
+	}
+	return ArraySolver
+}
+
+// placementTable returns the cached placement table of the game's shape and height
+func (g *G) placementTable() *placement.T {
+	return placement.Get(g.Shape, g.Volume.DimZ)
+}
+
+// freeMask returns the bitmask of all empty cells of the game volume, indexed like the
+// masks of the placement table
+func (g *G) freeMask(t *placement.T) bitmask.M {
+	free := bitmask.Zero
+	for x := 0; x < g.Volume.DimX; x++ {
+		for y := 0; y < g.Volume.DimY; y++ {
+			for z := 0; z < g.Volume.DimZ; z++ {
+				if g.Volume.Get(x, y, z) == 0 {
+					free = free.Set(t.Index(x, y, z))
+				}
+			}
+		}
+	}
+	return free
+}
+
+// bitPlacements lists for each block of the game all shape/shift combinations that fit into
+// the free cells of the volume. The order is identical to the one used by recursiveSolver
+func (g *G) bitPlacements(t *placement.T, free bitmask.M) [][]placement.P {
+	placements := make([][]placement.P, g.Blocks.Count)
+	for blockIdx, block := range g.Blocks.AsSlice() {
+		placements[blockIdx] = make([]placement.P, 0)
+		for _, pl := range t.ForBlock(block) {
+			// placements colliding with the initial volume can never be used
+			if pl.Mask.AndNot(free).IsZero() {
+				placements[blockIdx] = append(placements[blockIdx], pl)
+			}
+		}
+	}
+	return placements
+}
+
+// bitState contains the precomputed data and working arrays of the bitmask solver
+type bitState struct {
+	// placements lists the possible placements for each block
+	placements [][]placement.P
+
+	// chosen contains for each block placed so far the index of the placement used
+	chosen []int
+
+	// neighbors contains the adjacent cells of each cell, only used for pruning
+	neighbors []bitmask.M
+
+	// copyOfPrevious is true for the blocks identical to the preceding block
+	copyOfPrevious []bool
+}
+
+// solveBitmask is the implementation of SolveContext for the BitmaskSolver, don't call directly
+func (g *G) solveBitmask(s *search) {
+	if g.Blocks.Count == 0 {
+		return
+	}
+
+	t := g.placementTable()
+	free := g.freeMask(t)
+	state := &bitState{placements: g.bitPlacements(t, free), chosen: make([]int, g.Blocks.Count), copyOfPrevious: g.copyOfPrevious()}
+	if s.pruner != nil {
+		state.neighbors = bitNeighbors(t)
+	}
+
+	g.bitmaskSolver(0, free, state, s)
+}
+
+// bitmaskSolver is the recursive part of solveBitmask, don't call directly
+func (g *G) bitmaskSolver(blockIdx int, free bitmask.M, state *bitState, s *search) {
+	// a copy of the previous block only uses the placements behind the one of the previous block
+	start := 0
+	if state.copyOfPrevious[blockIdx] {
+		start = state.chosen[blockIdx-1] + 1
+	}
+
+	for i := start; i < len(state.placements[blockIdx]); i++ {
+		if s.stopped() {
+			return
+		}
+		pl := state.placements[blockIdx][i]
+		if pl.Mask.AndNot(free).IsZero() && s.visit() {
+			state.chosen[blockIdx] = i
+			remaining := free.AndNot(pl.Mask)
+
+			if blockIdx == len(state.placements)-1 {
+				if remaining.IsZero() {
+					s.found(g.bitSolution(state.placements, state.chosen))
+				}
+			} else if s.pruner == nil || !hasBitDeadSpace(remaining, state.neighbors, s.pruner, s.pruner.from(blockIdx+1)) {
+				g.bitmaskSolver(blockIdx+1, remaining, state, s)
+			}
+		}
+	}
+}
+
+// bitSolution converts the chosen placements to a game solution. The placements of identical
+// blocks are sorted, so every solution has the same representation as with the BlockOrder strategy
+func (g *G) bitSolution(placements [][]placement.P, chosen []int) *gamesolution.S {
+	sorted := make([]int, len(chosen))
+	copy(sorted, chosen)
+	copies := g.copyOfPrevious()
+	for first := 0; first < len(sorted); {
+		last := first + 1
+		for last < len(sorted) && copies[last] {
+			last++
+		}
+		sort.Ints(sorted[first:last])
+		first = last
+	}
+
+	shapeIndices := make([]int, len(chosen))
+	shifts := make([]vector.V, len(chosen))
+	for blockIdx, i := range sorted {
+		shapeIndices[blockIdx] = placements[blockIdx][i].ShapeIndex
+		shifts[blockIdx] = placements[blockIdx][i].Shift
+	}
+	return gamesolution.New(g.Blocks.AsSlice(), shapeIndices, shifts)
+}
@@ -0,0 +1,168 @@
+package game
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"ubongo/card"
+)
+
+// Checkpoint persists the problems chosen by GenerateCardSet for each animal and dice
+// number to a file, so an interrupted generation can be resumed, skipping the work
+// already done. The file contains one JSON object per line and is only appended to,
+// so a crash while writing loses at most the last line
+type Checkpoint struct {
+	mu      sync.Mutex
+	file    *os.File
+	entries map[checkpointKey]checkpointEntry
+
+	// last is the key of the entry read or written last
+	last checkpointKey
+
+	// err is the first error writing to the file
+	err error
+}
+
+// checkpointKey identifies the problems of a dice number, generated with the given parameters
+type checkpointKey struct {
+	Seed             int64
+	SourceDifficulty card.UbongoDifficulty
+	TargetDifficulty card.UbongoDifficulty
+	Animal           card.UbongoAnimal
+	Height           int
+	BlockCount       int
+	Inventory        string
+	Players          int
+	DiceNumber       int
+}
+
+// checkpointEntry is a line of the checkpoint file
+type checkpointEntry struct {
+	checkpointKey
+
+	// Problems contains the block numbers of the problem per card number,
+	// empty if the dice number couldn't be filled
+	Problems map[int][]int
+
+	// Unfilled tells why the dice number couldn't be filled, nil if it was filled
+	Unfilled *UnfilledDice `json:",omitempty"`
+}
+
+// OpenCheckpoint opens the checkpoint file, creating it if it doesn't exist,
+// and reads the entries already stored. Incomplete lines are ignored
+func OpenCheckpoint(file string) (*Checkpoint, error) {
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Checkpoint{file: f, entries: make(map[checkpointKey]checkpointEntry)}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var entry checkpointEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			c.entries[entry.checkpointKey] = entry
+			c.last = entry.checkpointKey
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// terminate an incomplete last line, so the next entry starts on a line of its own
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			f.Write([]byte{'\n'})
+		}
+	}
+	return c, nil
+}
+
+// Close closes the checkpoint file
+func (c *Checkpoint) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.file.Close()
+}
+
+// Len returns the number of dice numbers stored in the checkpoint
+func (c *Checkpoint) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Seed returns the seed of the last entry of the checkpoint and true, or false if it is
+// empty. This allows to resume a generation without knowing its seed
+func (c *Checkpoint) Seed() (int64, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last.Seed, len(c.entries) > 0
+}
+
+// Players returns the number of players of the last entry of the checkpoint and true, or
+// false if it is empty. Like Seed, this allows to resume a generation with its parameters
+func (c *Checkpoint) Players() (int, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last.Players, len(c.entries) > 0
+}
+
+// Err returns the first error that occurred writing to the checkpoint file, nil if there was none
+func (c *Checkpoint) Err() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// lookup returns the stored entry and true, or false if the key is not part of the checkpoint
+func (c *Checkpoint) lookup(key checkpointKey) (checkpointEntry, bool) {
+	if c == nil {
+		return checkpointEntry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+// store appends the entry to the checkpoint file. Errors are kept and returned by Err
+func (c *Checkpoint) store(entry checkpointEntry) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[entry.checkpointKey] = entry
+	c.last = entry.checkpointKey
+	if c.err != nil {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err == nil {
+		_, err = c.file.Write(append(line, '\n'))
+	}
+	if err == nil {
+		err = c.file.Sync()
+	}
+	c.err = err
+}
@@ -0,0 +1,213 @@
+package game
+
+import (
+	"context"
+	"fmt"
+
+	"ubongo/base/bitmask"
+	"ubongo/base/vector"
+	"ubongo/gamesolution"
+	"ubongo/placement"
+)
+
+// Reason is an enum describing why a game cannot be solved
+type Reason int
+
+// Enumeration values of the Reason enum
+const (
+	// VolumeMismatch means the volume of the blocks differs from the empty volume of the game
+	VolumeMismatch Reason = iota
+	// NoPlacement means a block doesn't fit anywhere into the empty volume
+	NoPlacement
+	// UncoverableCell means an empty cell can't be covered by any placement of any block
+	UncoverableCell
+	// ParityViolation means the blocks can't cover the same number of black and white cells
+	// as the volume contains, if it is colored like a 3D checkerboard
+	ParityViolation
+	// SearchExhausted means the whole search tree was explored without finding a solution
+	SearchExhausted
+	// Undecided means the search was stopped by the limits before a solution was found
+	Undecided
+)
+
+// String returns a string representation for the Reason enum
+func (r Reason) String() string {
+	switch r {
+	case VolumeMismatch:
+		return "VolumeMismatch"
+	case NoPlacement:
+		return "NoPlacement"
+	case UncoverableCell:
+		return "UncoverableCell"
+	case ParityViolation:
+		return "ParityViolation"
+	case SearchExhausted:
+		return "SearchExhausted"
+	case Undecided:
+		return "Undecided"
+	}
+	return "Unknown"
+}
+
+// Explanation is a single reason returned by Diagnose
+type Explanation struct {
+	Reason Reason
+
+	// BlockNumber is the block without placement, only set for NoPlacement
+	BlockNumber int
+
+	// Cell is the cell that can't be covered, only set for UncoverableCell
+	Cell vector.V
+
+	// Expected and Actual are the volumes for VolumeMismatch (empty volume, block volume) and
+	// the difference between black and white cells for ParityViolation (volume, closest
+	// difference the blocks can reach)
+	Expected, Actual int
+
+	// Nodes is the number of search-tree nodes visited, only set for SearchExhausted and Undecided
+	Nodes int
+}
+
+// String returns a human readable description of the explanation
+func (e Explanation) String() string {
+	switch e.Reason {
+	case VolumeMismatch:
+		return fmt.Sprintf("The empty volume is %d, but the blocks have a volume of %d", e.Expected, e.Actual)
+	case NoPlacement:
+		return fmt.Sprintf("Block number %d doesn't fit anywhere into the volume", e.BlockNumber)
+	case UncoverableCell:
+		return fmt.Sprintf("No block can cover the cell %s", e.Cell)
+	case ParityViolation:
+		return fmt.Sprintf("Colored like a checkerboard, the volume has %d more black than white cells, the blocks can't cover this (closest %d)",
+			e.Expected, e.Actual)
+	case SearchExhausted:
+		return fmt.Sprintf("The exhaustive search failed after %d nodes", e.Nodes)
+	case Undecided:
+		return fmt.Sprintf("No solution was found within the limits (%d nodes)", e.Nodes)
+	}
+	return e.Reason.String()
+}
+
+// Diagnose explains why the game can't be solved. The quick checks (volume, placements,
+// cells and parity) are done first, all their findings are returned. Only if they find
+// nothing, a search limited by ctx and opts is started. The checks of the placements,
+// cells and parity are skipped if the volume is too big for a bitmask.
+// Returns an empty slice if the game has a solution
+func (g *G) Diagnose(ctx context.Context, opts SolveOptions) []Explanation {
+	explanations := make([]Explanation, 0)
+	if g == nil {
+		return explanations
+	}
+
+	if g.Volume.Count(0) != g.Blocks.Volume() {
+		explanations = append(explanations, Explanation{Reason: VolumeMismatch, Expected: g.Volume.Count(0), Actual: g.Blocks.Volume()})
+	}
+	if g.fitsBitmask() {
+		explanations = append(explanations, g.diagnosePlacements(len(explanations) == 0)...)
+	}
+	if len(explanations) > 0 {
+		return explanations
+	}
+
+	// search for a single solution
+	sub := g.Clone()
+	sub.Solver = g.fastestSolver()
+	sub.Strategy = BlockOrder
+	sub.Pruning = true
+	sub.Distinct = false
+	solved := false
+	s := newSearch(ctx, opts, func(*gamesolution.S) bool {
+		solved = true
+		return false
+	})
+	sub.run(s)
+
+	if !solved && s.status == Complete {
+		explanations = append(explanations, Explanation{Reason: SearchExhausted, Nodes: s.nodes})
+	} else if !solved {
+		explanations = append(explanations, Explanation{Reason: Undecided, Nodes: s.nodes})
+	}
+	return explanations
+}
+
+// diagnosePlacements returns the blocks without placement and the cells no block can cover,
+// and if there are none and parity is set, a parity violation. Called by Diagnose
+// for volumes fitting into a bitmask, don't call directly
+func (g *G) diagnosePlacements(parity bool) []Explanation {
+	explanations := make([]Explanation, 0)
+	t := g.placementTable()
+	free := g.freeMask(t)
+	placements := g.bitPlacements(t, free)
+
+	// blocks without placement, and the cells covered by at least one placement
+	coverable := bitmask.Zero
+	for blockIdx, pls := range placements {
+		if len(pls) == 0 && (blockIdx == 0 || g.Blocks.Get(blockIdx-1).Number != g.Blocks.Get(blockIdx).Number) {
+			explanations = append(explanations, Explanation{Reason: NoPlacement, BlockNumber: g.Blocks.Get(blockIdx).Number})
+		}
+		for _, pl := range pls {
+			coverable = coverable.Or(pl.Mask)
+		}
+	}
+	for cells := free.AndNot(coverable); !cells.IsZero(); {
+		cell := cells.Lowest()
+		cells = cells.Clear(cell)
+		explanations = append(explanations, Explanation{Reason: UncoverableCell, Cell: t.Cell(cell)})
+	}
+
+	if len(explanations) == 0 && parity {
+		if expected, closest, ok := checkParity(t, free, placements); !ok {
+			explanations = append(explanations, Explanation{Reason: ParityViolation, Expected: expected, Actual: closest})
+		}
+	}
+	return explanations
+}
+
+// checkParity colors the cells like a 3D checkerboard and checks if the difference between
+// black and white cells of the volume can be reached by a combination of placements of the
+// blocks. Returns the difference of the volume, the reachable difference closest to it and
+// true if the difference can be reached
+func checkParity(t *placement.T, free bitmask.M, placements [][]placement.P) (int, int, bool) {
+	black := bitmask.Zero
+	for i := 0; i < t.Dim[0]*t.Dim[1]*t.Dim[2]; i++ {
+		if c := t.Cell(i); (c[0]+c[1]+c[2])%2 == 0 {
+			black = black.Set(i)
+		}
+	}
+	difference := func(m bitmask.M) int {
+		return m.And(black).Count() - m.AndNot(black).Count()
+	}
+	expected := difference(free)
+
+	// differences reachable with the blocks processed so far
+	reachable := map[int]bool{0: true}
+	for _, pls := range placements {
+		diffs := make(map[int]bool)
+		for _, pl := range pls {
+			diffs[difference(pl.Mask)] = true
+		}
+		next := make(map[int]bool)
+		for r := range reachable {
+			for d := range diffs {
+				next[r+d] = true
+			}
+		}
+		reachable = next
+	}
+
+	closest, found := 0, false
+	for r := range reachable {
+		if !found || abs(r-expected) < abs(closest-expected) || abs(r-expected) == abs(closest-expected) && r < closest {
+			closest, found = r, true
+		}
+	}
+	return expected, closest, reachable[expected]
+}
+
+// abs returns the absolute value of x
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,172 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"ubongo/block"
+	"ubongo/card"
+	"ubongo/cardfactory"
+	"ubongo/problem"
+)
+
+// Features are the measurable properties of a problem used to rate its difficulty
+type Features struct {
+	// SolutionCount is the number of solutions of the problem
+	SolutionCount int
+
+	// Nodes is the number of search-tree nodes visited to find all solutions with the
+	// BitmaskSolver (the ArraySolver for volumes too big for a bitmask), without pruning
+	Nodes int
+
+	// Placements is the average number of legal placements per block in the empty volume
+	Placements float64
+
+	// NonFlatBlocks is the number of blocks that can't be laid flat, i.e. have no shape
+	// that is only one cube thick
+	NonFlatBlocks int
+
+	// Volume of the problem in unit cubes
+	Volume int
+
+	// Compactness is the area of the shape divided by the area of its bounding box
+	Compactness float64
+}
+
+// Calibration maps the raw scores of the features to difficulty scores
+type Calibration struct {
+	// Easy and Difficult are the mean raw scores of the problems of the original Easy
+	// and Difficult cards, which are mapped to the difficulty scores 1 and 2
+	Easy, Difficult float64
+}
+
+// DefaultCalibration is the calibration against the cards of the original game,
+// as calculated by Calibrate(cardfactory.Get())
+var DefaultCalibration = Calibration{Easy: 12.2766, Difficult: 15.9358}
+
+// Measure determines the features of the given problem
+func Measure(p *problem.P) Features {
+	if p == nil {
+		panic("Problem must not be nil")
+	}
+
+	f, _ := measureContext(context.Background(), p)
+	return f
+}
+
+// measureContext works like Measure, but stops the search if ctx is cancelled.
+// Returns the features and true if the search was complete, false otherwise
+func measureContext(ctx context.Context, p *problem.P) (Features, bool) {
+	g := New(p)
+	g.Solver = g.fastestSolver()
+	result := g.SolveContext(ctx, SolveOptions{})
+	if result.Status != Complete {
+		return Features{}, false
+	}
+	return measureSolved(p, g, result), true
+}
+
+// measureSolved determines the features of the problem p from the result of a complete
+// search of the game g, which must have been created for p with its fastest solver and
+// without pruning. This avoids solving the problem again if the solutions are needed anyway
+func measureSolved(p *problem.P, g *G, result SolveResult) Features {
+	f := Features{
+		SolutionCount: len(result.Solutions),
+		Nodes:         result.Nodes,
+		Volume:        p.Area * p.Height,
+		Compactness:   float64(p.Area) / float64(p.Shape.DimX*p.Shape.DimY),
+	}
+
+	if p.Blocks.Count > 0 {
+		total := 0
+		if g.fitsBitmask() {
+			t := g.placementTable()
+			for _, placements := range g.bitPlacements(t, g.freeMask(t)) {
+				total += len(placements)
+			}
+		} else {
+			gameBox := g.Volume.GetBoundingBox()
+			for _, b := range p.Blocks.AsSlice() {
+				for _, shape := range b.Shapes {
+					for _, shift := range gameBox.GetShiftVectors(shape.GetBoundingBox()) {
+						if g.fits(shape, shift) {
+							total++
+						}
+					}
+				}
+			}
+		}
+		f.Placements = float64(total) / float64(p.Blocks.Count)
+	}
+
+	for _, b := range p.Blocks.AsSlice() {
+		if !isFlat(b) {
+			f.NonFlatBlocks++
+		}
+	}
+
+	return f
+}
+
+// isFlat returns true if the block has a shape that is only one cube thick
+func isFlat(b *block.B) bool {
+	for _, s := range b.Shapes {
+		if s.DimX == 1 || s.DimY == 1 || s.DimZ == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// raw combines the features to an uncalibrated score. Searches with many nodes, few
+// solutions, many placements per block, non-flat blocks, big volumes and ragged shapes
+// make a problem harder
+func (f Features) raw() float64 {
+	return math.Log2(1+float64(f.Nodes)) -
+		math.Log2(float64(f.SolutionCount)) +
+		math.Log2(1+f.Placements) +
+		0.5*float64(f.NonFlatBlocks) +
+		0.1*float64(f.Volume) +
+		2*(1-f.Compactness)
+}
+
+// Score returns the difficulty score of the features, calibrated such that an average
+// problem of the original Easy cards has the score 1, and one of the Difficult cards 2.
+// Problems without solution have the score 0
+func (f Features) Score(c Calibration) float64 {
+	if f.SolutionCount == 0 {
+		return 0
+	}
+	return 1 + (f.raw()-c.Easy)/(c.Difficult-c.Easy)
+}
+
+// Rate returns the difficulty score of the problem using the DefaultCalibration
+func Rate(p *problem.P) float64 {
+	return Measure(p).Score(DefaultCalibration)
+}
+
+// Calibrate calculates the calibration of the difficulty scores from the problems of the
+// Easy and Difficult cards of the given card factory. Panics if there is no problem with
+// a solution for one of the difficulties
+func Calibrate(cf *cardfactory.F) Calibration {
+	if cf == nil {
+		panic("CardFactory must not be nil")
+	}
+
+	mean := func(difficulty card.UbongoDifficulty) float64 {
+		sum, count := 0.0, 0
+		for _, p := range cf.GetAllProblems(difficulty) {
+			if f := Measure(p); f.SolutionCount > 0 {
+				sum += f.raw()
+				count++
+			}
+		}
+		if count == 0 {
+			panic(fmt.Sprintf("No problem with a solution on the %s cards to calibrate with", difficulty))
+		}
+		return sum / float64(count)
+	}
+
+	return Calibration{Easy: mean(card.Easy), Difficult: mean(card.Difficult)}
+}
@@ -3,22 +3,27 @@
 package game
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"math/rand"
 	"os"
 	"sort"
 	"strconv"
+	"time"
 
 	"ubongo/base/array2d"
 	"ubongo/base/array3d"
 	"ubongo/base/vector"
+	"ubongo/block"
 	"ubongo/blockfactory"
 	"ubongo/blockset"
 	"ubongo/card"
 	"ubongo/cardfactory"
 	"ubongo/gamesolution"
+	"ubongo/inventory"
 	"ubongo/problem"
+	"ubongo/symmetry"
 )
 
 // G is the main type of the package, representing a game
@@ -31,30 +36,43 @@ type G struct {
 
 	// Blocks is the set of blocks from which to build the solution
 	Blocks *blockset.S
+
+	// Solver selects the algorithm used by Solve(), all solvers return identical results.
+	// Volumes too big for a bitmask (see placement.Fits) are solved by the ArraySolver
+	Solver SolverType
+
+	// Pruning enables the dead-space pruning of the solvers: after each block placed, the search
+	// is aborted if a void of the volume cannot be filled with the remaining blocks
+	Pruning bool
+
+	// Strategy selects the order in which the search tree is explored
+	Strategy SearchStrategy
+
+	// Distinct makes the solvers return only one solution per class of solutions that are
+	// geometrically the same arrangement turned around by a symmetry of the extruded Shape
+	Distinct bool
 }
 
-// UbonboBlockSet lists the number of blocks of each type in the original Ubongo game
-// map[BlockNumer]Count
-// This is mainly used by the method GenerateCardSet that must make sure that for any
-// dice number enough blocks will be available in the game to solve the problems with
-// 4 players participating
-var UbongoBlockSet map[int]int = map[int]int{
-	1:  2, // yellow hello
-	2:  2, // yellow bighook
-	3:  3, // yellow smallhook
-	4:  2, // yellow gate
-	5:  2, // blue bighook
-	6:  2, // blue flash
-	7:  3, // blue lighter
-	8:  4, // blue v
-	9:  3, // red stool
-	10: 3, // red smallhook
-	11: 2, // red bighook
-	12: 2, // red flash
-	13: 2, // green flash
-	14: 2, // green bighook
-	15: 2, // green T
-	16: 4, // green L
+// SolverType is an enum selecting the algorithm used to solve a game
+type SolverType int
+
+// Enumeration values of the SolverType enum
+const (
+	// ArraySolver is the reference solver, working directly on the 3D volume array
+	ArraySolver SolverType = iota
+	// BitmaskSolver represents the volume and all block placements as bitmasks
+	BitmaskSolver
+)
+
+// String returns a string representation for the SolverType enum
+func (s SolverType) String() string {
+	switch s {
+	case ArraySolver:
+		return "ArraySolver"
+	case BitmaskSolver:
+		return "BitmaskSolver"
+	}
+	return "Unknown"
 }
 
 // New creates a new game, initialized with the given shape and height and an empty volume
@@ -94,9 +112,13 @@ func (g *G) Clone() *G {
 		return nil
 	} else {
 		return &G{
-			Shape:  g.Shape.Clone(),
-			Volume: g.Volume.Clone(),
-			Blocks: g.Blocks.Clone()}
+			Shape:    g.Shape.Clone(),
+			Volume:   g.Volume.Clone(),
+			Blocks:   g.Blocks.Clone(),
+			Solver:   g.Solver,
+			Pruning:  g.Pruning,
+			Strategy: g.Strategy,
+			Distinct: g.Distinct}
 	}
 }
 
@@ -107,22 +129,9 @@ func (g *G) TryAddBlock(block *array3d.A, pos vector.V) bool {
 		return false
 	} else {
 
-		// check overall dimensions
-		if pos[0]+block.DimX > g.Volume.DimX ||
-			pos[1]+block.DimY > g.Volume.DimY ||
-			pos[2]+block.DimZ > g.Volume.DimZ {
-			return false
-		}
-
 		// step 1: test if it is possible to add block
-		for x := 0; x < block.DimX; x++ {
-			for y := 0; y < block.DimY; y++ {
-				for z := 0; z < block.DimZ; z++ {
-					if block.Get(x, y, z) == 1 && g.Volume.Get(x+pos[0], y+pos[1], z+pos[2]) != 0 {
-						return false
-					}
-				}
-			}
+		if !g.fits(block, pos) {
+			return false
 		}
 
 		// step 2: actually add the block, the expensive step is the cloning of the object
@@ -143,6 +152,27 @@ func (g *G) TryAddBlock(block *array3d.A, pos vector.V) bool {
 	}
 }
 
+// fits returns true if the block can be added to the game volume at the given position
+func (g *G) fits(block *array3d.A, pos vector.V) bool {
+	// check overall dimensions
+	if pos[0]+block.DimX > g.Volume.DimX ||
+		pos[1]+block.DimY > g.Volume.DimY ||
+		pos[2]+block.DimZ > g.Volume.DimZ {
+		return false
+	}
+
+	for x := 0; x < block.DimX; x++ {
+		for y := 0; y < block.DimY; y++ {
+			for z := 0; z < block.DimZ; z++ {
+				if block.Get(x, y, z) == 1 && g.Volume.Get(x+pos[0], y+pos[1], z+pos[2]) != 0 {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
 // RemoveBlock removes the block at the given position from the volume
 // This does not check if the block is actually present and
 // simply sets all values from 1 to 0
@@ -176,51 +206,54 @@ func (g *G) RemoveBlock(block *array3d.A, pos vector.V) bool {
 }
 
 // Solve finds all solutino for a given game using the set of blocks provided
+// The algorithm used is selected by the Solver field of the game
 func (g *G) Solve() []*gamesolution.S {
-	if g == nil {
-		return []*gamesolution.S{}
-	} else {
-
-		// check the sum of the block volumes, it must match the empty volume of the game to yield a solution
-		if g.Volume.Count(0) != g.Blocks.Volume() {
-			return []*gamesolution.S{}
-		}
-
-		// working arrays for the recursive solver:
-		solutions := make([]*gamesolution.S, 0)
-		shapeIdx := make([]int, 0)
-		shifts := make([]vector.V, 0)
-
-		g.recursiveSolver(0, &shapeIdx, &shifts, &solutions)
-
-		return solutions
-	}
+	return g.SolveContext(context.Background(), SolveOptions{}).Solutions
 }
 
-// recursiveSolver function is called by Solve, don't call directly
-func (g *G) recursiveSolver(blockIdx int, shapeIndices *[]int, shifts *[]vector.V, solutions *[]*gamesolution.S) {
+// recursiveSolver function is called by SolveContext, don't call directly
+func (g *G) recursiveSolver(blockIdx int, shapeIndices *[]int, shifts *[]vector.V, s *search) {
 	gameBox := g.Volume.GetBoundingBox()
 
 	block := g.Blocks.Get(blockIdx)
 
+	// a copy of the previous block must be placed behind it (in the order of shapes and shifts),
+	// otherwise all permutations of identical blocks would be found as separate solutions
+	isCopy := blockIdx > 0 && g.Blocks.Get(blockIdx-1).Number == block.Number
+	prevShapeIdx, prevShift := 0, vector.V{}
+	if isCopy {
+		prevShapeIdx, prevShift = (*shapeIndices)[blockIdx-1], (*shifts)[blockIdx-1]
+	}
+
 	for shapeIdx, shape := range block.Shapes {
+		if isCopy && shapeIdx < prevShapeIdx {
+			continue
+		}
 		*shapeIndices = append(*shapeIndices, shapeIdx)
 
 		shiftVectors := gameBox.GetShiftVectors(shape.GetBoundingBox())
 		for _, shift := range shiftVectors {
+			if s.stopped() {
+				break
+			}
+			if isCopy && shapeIdx == prevShapeIdx && !shiftLess(prevShift, shift) {
+				continue
+			}
 			if ok := g.TryAddBlock(shape, shift); ok {
 
 				*shifts = append(*shifts, shift)
 
-				// if this was the last block, stop recursion
-				if blockIdx == g.Blocks.Count-1 {
-					// check if we have a solution
-					if g.Volume.Count(0) == 0 {
-						*solutions = append(*solutions, gamesolution.New(g.Blocks.AsSlice(), *shapeIndices, *shifts))
+				if s.visit() {
+					// if this was the last block, stop recursion
+					if blockIdx == g.Blocks.Count-1 {
+						// check if we have a solution
+						if g.Volume.Count(0) == 0 {
+							s.found(gamesolution.New(g.Blocks.AsSlice(), *shapeIndices, *shifts))
+						}
+						// if it wasn't the last block, continue recursion
+					} else if s.pruner == nil || !g.hasDeadSpace(s.pruner, s.pruner.from(blockIdx+1)) {
+						g.recursiveSolver(blockIdx+1, shapeIndices, shifts, s)
 					}
-					// if it wasn't the last block, continue recursion
-				} else {
-					g.recursiveSolver(blockIdx+1, shapeIndices, shifts, solutions)
 				}
 
 				*shifts = (*shifts)[:len(*shifts)-1]
@@ -231,9 +264,32 @@ func (g *G) recursiveSolver(blockIdx int, shapeIndices *[]int, shifts *[]vector.
 
 		*shapeIndices = (*shapeIndices)[:len(*shapeIndices)-1]
 
+		if s.stopped() {
+			break
+		}
 	} // end loop over shapes
 }
 
+// shiftLess returns true if shift a comes before shift b in the order of vector.GetShiftVectors
+func shiftLess(a, b vector.V) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// copyOfPrevious returns for each block of the game whether it is identical to the
+// preceding block of the blockset. As blocksets are sorted, all copies are adjacent
+func (g *G) copyOfPrevious() []bool {
+	copies := make([]bool, g.Blocks.Count)
+	for i := 1; i < g.Blocks.Count; i++ {
+		copies[i] = g.Blocks.Get(i).Number == g.Blocks.Get(i-1).Number
+	}
+	return copies
+}
+
 // SolutionStatiscitsRecord represents a single entry of the output of CreateSolutionStatistics()
 type SolutionStatisticsRecord struct {
 	Difficulty    card.UbongoDifficulty
@@ -244,30 +300,60 @@ type SolutionStatisticsRecord struct {
 	Height        int
 	SolutionCount int
 	Blocks        *blockset.S
+
+	// DistinctSolutionCount is the number of solutions that are not symmetric copies of each other
+	DistinctSolutionCount int
+
+	// DifficultyScore is the calibrated difficulty of the problem, see Rate
+	DifficultyScore float64
 }
 
 // CreateSolutionStatistics solves all Easy & Difficult problems and returns the statistics
 // If the csvFile parameter is provided (and not empty), the data is also
 // written to a csv file
 func CreateSolutionStatistics(f *cardfactory.F, csvFile string) []SolutionStatisticsRecord {
+	return createSolutionStatistics(f, csvFile, 1)
+}
+
+// CreateSolutionStatisticsParallel works like CreateSolutionStatistics, but solves the
+// problems concurrently on the given number of workers. The result and the csv file
+// are identical to the ones of CreateSolutionStatistics.
+// If workers is smaller than 1, runtime.GOMAXPROCS(0) workers are used
+func CreateSolutionStatisticsParallel(f *cardfactory.F, csvFile string, workers int) []SolutionStatisticsRecord {
+	return createSolutionStatistics(f, csvFile, workers)
+}
+
+// createSolutionStatistics implements CreateSolutionStatistics and CreateSolutionStatisticsParallel
+func createSolutionStatistics(f *cardfactory.F, csvFile string, workers int) []SolutionStatisticsRecord {
 	if f == nil {
 		panic("CardFactory must not be nil")
 	}
 
-	// create the dataset to return / write
+	// create the dataset to return / write, the solution count is filled in below
 	records := make([]SolutionStatisticsRecord, 0)
+	problems := make([]*problem.P, 0)
 	for _, difficulty := range []card.UbongoDifficulty{card.Easy, card.Difficult} {
 		for _, c := range f.GetAll(difficulty) {
 			for diceNumber, p := range c.Problems {
-				g := New(p)
-				solutions := g.Solve()
 				records = append(records, SolutionStatisticsRecord{
 					c.Difficulty, c.Animal, c.CardNumber, diceNumber, p.Area, p.Height,
-					len(solutions), p.Blocks})
+					0, p.Blocks, 0, 0})
+				problems = append(problems, p)
 			}
 		}
 	}
 
+	// solve the problems, the difficulty score is measured from the same search,
+	// so every problem is solved once
+	forEachParallel(workers, len(records), func(i int) {
+		g := New(problems[i])
+		g.Solver = g.fastestSolver()
+		result := g.SolveContext(context.Background(), SolveOptions{})
+		records[i].SolutionCount = len(result.Solutions)
+		records[i].DistinctSolutionCount = len(symmetry.New(problems[i]).Distinct(result.Solutions))
+		records[i].DifficultyScore = measureSolved(problems[i], g, result).Score(DefaultCalibration)
+	})
+
 	// order the problems by difficulty, cardnumber, dicenumber
 	sortOrder := func(rec SolutionStatisticsRecord) int {
 		return int(rec.Difficulty)*10000000 + rec.CardNumber*1000 + rec.DiceNumber
@@ -286,7 +372,7 @@ func CreateSolutionStatistics(f *cardfactory.F, csvFile string) []SolutionStatis
 
 		w := csv.NewWriter(file)
 		defer w.Flush()
-		w.Write([]string{"Difficulty", "Animal", "CardNumber", "DiceNumber", "Area", "Height", "SolutionCount", "Blocks"})
+		w.Write([]string{"Difficulty", "Animal", "CardNumber", "DiceNumber", "Area", "Height", "SolutionCount", "Blocks", "DistinctSolutionCount", "DifficultyScore"})
 		for _, rec := range records {
 			err = w.Write([]string{
 				rec.Difficulty.String(),
@@ -297,6 +383,8 @@ func CreateSolutionStatistics(f *cardfactory.F, csvFile string) []SolutionStatis
 				strconv.Itoa(rec.Height),
 				strconv.Itoa(rec.SolutionCount),
 				rec.Blocks.String(),
+				strconv.Itoa(rec.DistinctSolutionCount),
+				strconv.FormatFloat(rec.DifficultyScore, 'f', 3, 64),
 			})
 			if err != nil {
 				panic(fmt.Sprintf("Error writing solution statistics file: %v", err))
@@ -308,45 +396,65 @@ func CreateSolutionStatistics(f *cardfactory.F, csvFile string) []SolutionStatis
 }
 
 // IsPossibleCardSet verifies if the set of problems given can be used
-// as a set in the game. Condition is that the combined
-// blocks are available in the game
-// The map-key is the card-number
-func IsPossibleCardSet(problems map[int]*problem.P) bool {
+// as a set in the game with the given number of players, each playing one card.
+// Condition is that for any choice of cards by the players the combined blocks are
+// available in the inventory, counting every copy of a block in a multiset.
+// If there are less cards than players, all cards must be playable together.
+// The map-key is the card-number. Panics if inv is nil or players is < 1
+func IsPossibleCardSet(problems map[int]*problem.P, inv *inventory.I, players int) bool {
+	if inv == nil {
+		panic("Inventory must not be nil")
+	}
+	if players < 1 {
+		panic("Players must be >= 1")
+	}
 	if len(problems) == 0 {
 		return false
 	}
 
-	blockStat := map[int]int{} // counts the blocks per blocknumber
+	sets := make([]*blockset.S, 0, len(problems))
 	for _, p := range problems {
 		if p == nil {
 			return false
 		}
-		for _, block := range p.Blocks.AsSlice() {
-			if _, ok := blockStat[block.Number]; !ok {
-				blockStat[block.Number] = 1
-			} else {
-				blockStat[block.Number] += 1
-			}
-		}
+		sets = append(sets, p.Blocks)
 	}
-
-	for blockNum, blockCount := range blockStat {
-		if blockCount > UbongoBlockSet[blockNum] {
-			return false
+	players = min(players, len(sets))
+
+	// check all combinations of cards played at the same time, the sets with
+	// fewer cards are covered by the bigger ones
+	var check func(start int, chosen []*blockset.S) bool
+	check = func(start int, chosen []*blockset.S) bool {
+		if len(chosen) == players {
+			return inv.Contains(chosen...)
 		}
+		for i := start; i <= len(sets)-(players-len(chosen)); i++ {
+			if !check(i+1, append(chosen, sets[i])) {
+				return false
+			}
+		}
+		return true
 	}
-
-	return true
+	return check(0, make([]*blockset.S, 0, players))
 }
 
 // GenerateCardSet creates 10 sets of problems for diceNumber=1..10 where each problem set
 // consists of the 4 cards associated with the given animal and the blocks are
-// chosen such that the game contains enough blocks to play a round with 4 people
-// for every possible throw of the dice (and of course every problem has a solution)
+// chosen such that the inventory contains enough blocks to play a round with the number
+// of players given in opts for every possible throw of the dice (and of course every
+// problem has a solution)
 // Returns: map[diceNumber][cardNumber]*Problem
-// Optionally write the result to the given file, if not empty
-func GenerateCardSet(bc *cardfactory.F, bf *blockfactory.F,
-	animal card.UbongoAnimal, sourceDifficulty, targetDifficulty card.UbongoDifficulty, height, blockCount int, outputFile string) []*card.C {
+// The same seed and parameters yield the same cards, see CardSetOptions.
+// The candidate problems of all cards and dice numbers are tested on a shared pool of
+// opts.Workers goroutines, the result doesn't depend on the number of workers.
+// The problems of a dice number are combined by an exhaustive search, so they are only missing
+// if no consistent combination exists; these dice numbers are returned with the reason.
+// If ctx is cancelled, all problem generations are stopped and the cards completed so far
+// are returned together with the error of the context, dice numbers not completed are missing.
+// Optionally write the result to the given file, if not empty and not cancelled, starting with the seed
+func GenerateCardSet(ctx context.Context, bc *cardfactory.F, bf *blockfactory.F,
+	animal card.UbongoAnimal, sourceDifficulty, targetDifficulty card.UbongoDifficulty, height, blockCount int,
+	opts CardSetOptions, outputFile string) ([]*card.C, []UnfilledDice, error) {
 
 	if bc == nil || bf == nil {
 		panic("CardFactory and BlockFactory must not be nil")
@@ -357,17 +465,14 @@ func GenerateCardSet(bc *cardfactory.F, bf *blockfactory.F,
 	if blockCount < 1 {
 		panic("BlockCount must be >= 1")
 	}
+	if opts.Players < 0 {
+		panic("Players must be >= 0")
+	}
 
 	// ** Utility types / functions and constants ** //
 
-	maxTry := 200               // number of tries to build a consistent problem set
 	numProblemsPerDiceNum := 20 // number of problems to generate per diceNumber and card
 
-	// this key is used in the 'problems' map
-	type key struct {
-		animal     card.UbongoAnimal
-		diceNumber int
-	}
 	// this function selects a shape from a given card with a diceNumber
 	shapeSelector := func(card *card.C, diceNumber int) *array2d.A {
 		if diceNumber <= 5 {
@@ -376,71 +481,151 @@ func GenerateCardSet(bc *cardfactory.F, bf *blockfactory.F,
 			return card.Problems[8].Shape // bottom shape
 		}
 	}
+	seed, checkpoint := opts.Seed, opts.Checkpoint
+	inv, players := opts.Inventory, opts.Players
+	if inv == nil {
+		inv = inventory.Ubongo()
+	}
+	if players == 0 {
+		players = 4
+	}
 
-	// ** Generate problems ** //
-	problems := map[key]map[int][]*problem.P{} // value of map: map[cardnumber](problems with with same animal/dice/cardnum)
-	sourceCards := bc.GetByAnimal(sourceDifficulty, animal)
-
-	type item struct {
-		key        key
-		cardNumber int
-		problems   []*problem.P
-	}
-	queueSize := 10 * len(sourceCards)
-	queue := make(chan item, queueSize)
-	for _, card := range sourceCards {
-		for diceNumber := 1; diceNumber <= 10; diceNumber++ {
-			shape := shapeSelector(card, diceNumber)
-			curKey := key{animal, diceNumber}
-			// create new entry in map if necessary
-			if _, ok := problems[curKey]; !ok {
-				problems[curKey] = map[int][]*problem.P{}
-			}
-			go func(cardNum int) {
-				probs := GenerateProblems(bf, shape, height, blockCount, numProblemsPerDiceNum)
-				queue <- item{curKey, cardNum, probs}
-			}(card.CardNumber)
-		}
+	// this function returns the key of a dice number in the checkpoint
+	checkpointKeyOf := func(diceNumber int) checkpointKey {
+		return checkpointKey{seed, sourceDifficulty, targetDifficulty, animal, height, blockCount,
+			inv.String(), players, diceNumber}
 	}
-	// read results from channel
-	for i := 0; i < queueSize; i++ {
-		el := <-queue
-		problems[el.key][el.cardNumber] = el.problems
+	// this function reports the progress
+	start := time.Now()
+	progress := func(cardNumber, diceNumber int, report GenerateReport, done bool) {
+		if opts.Progress != nil {
+			opts.Progress(CardSetProgress{animal, cardNumber, diceNumber, report, done, time.Since(start)})
+		}
 	}
 
 	// ** Initialize set of cards to return ** //
 
+	sourceCards := bc.GetByAnimal(sourceDifficulty, animal)
 	cardSet := make(map[int]*card.C) // key = CardNumber
 	for _, crd := range sourceCards {
 		cardSet[crd.CardNumber] = card.New(crd.CardNumber, targetDifficulty, animal, make(map[int]*problem.P))
 	}
 
-	// ** try to build sets for each diceNumber ** //
-
-	for diceNumber := 1; diceNumber <= 10; diceNumber++ {
-		curKey := key{animal, diceNumber}
-
-		for try := 0; try < maxTry; try++ {
-			// randomly choose one problem from each card/dicenum
-			problemSet := make(map[int]*problem.P) // key=CardNumber
-			for cardNum, probs := range problems[curKey] {
-				if len(probs) == 0 {
-					problemSet = nil
-					break
+	// the cards are processed in the order of their numbers to draw the random numbers reproducibly
+	cardNumbers := make([]int, 0, len(sourceCards))
+	for _, crd := range sourceCards {
+		cardNumbers = append(cardNumbers, crd.CardNumber)
+	}
+	sort.Ints(cardNumbers)
+
+	// ** Restore the dice numbers stored in the checkpoint ** //
+
+	// restore returns the problems of a stored entry and true, or false if the entry is
+	// corrupted, e.g. edited by hand or stale: unknown block numbers, a wrong number of blocks,
+	// missing cards, blocks not filling the volume or a set that can't be played with the
+	// inventory and number of players. Such a dice number is generated again
+	restore := func(stored checkpointEntry, diceNumber int) (map[int]*problem.P, bool) {
+		problems := make(map[int]*problem.P)
+		if stored.Unfilled != nil {
+			return problems, len(stored.Problems) == 0
+		}
+		for _, crd := range sourceCards {
+			numbers, ok := stored.Problems[crd.CardNumber]
+			if !ok || len(numbers) != blockCount {
+				return nil, false
+			}
+			blocks := make([]*block.B, len(numbers))
+			for i, number := range numbers {
+				if blocks[i] = bf.ByNumber(number); blocks[i] == nil {
+					return nil, false
 				}
-				problemSet[cardNum] = probs[rand.Intn(len(probs))]
 			}
-			if problemSet == nil {
-				break
+			p := problem.New(shapeSelector(crd, diceNumber), height, blockset.NewMultiset(blocks...))
+			if p.Blocks.Volume() != p.Area*p.Height {
+				return nil, false
 			}
-			if IsPossibleCardSet(problemSet) {
+			problems[crd.CardNumber] = p
+		}
+		return problems, IsPossibleCardSet(problems, inv, players)
+	}
+
+	pending := make(map[int]bool)           // dice numbers still to generate
+	unfilled := make(map[int]*UnfilledDice) // dice numbers that couldn't be filled
+	for diceNumber := 1; diceNumber <= 10; diceNumber++ {
+		stored, ok := checkpoint.lookup(checkpointKeyOf(diceNumber))
+		var problems map[int]*problem.P
+		if ok {
+			problems, ok = restore(stored, diceNumber)
+		}
+		if !ok {
+			pending[diceNumber] = true
+			continue
+		}
+		if stored.Unfilled != nil {
+			unfilled[diceNumber] = stored.Unfilled
+		}
+		for cardNum, p := range problems {
+			cardSet[cardNum].Problems[diceNumber] = p
+		}
+	}
+
+	// ** Generate problems ** //
+
+	// all cards and dice numbers share one worker pool, the dice numbers are processed in
+	// ascending order, so the first ones are complete and stored in the checkpoint early
+	sourceByNumber := make(map[int]*card.C)
+	for _, crd := range sourceCards {
+		sourceByNumber[crd.CardNumber] = crd
+	}
+	problems := make(map[int]map[int][]*problem.P) // map[diceNumber][cardNumber](problems)
+	gens := make([]*generation, 0)
+	for diceNumber := 1; diceNumber <= 10; diceNumber++ {
+		if !pending[diceNumber] {
+			continue
+		}
+		problems[diceNumber] = make(map[int][]*problem.P)
+		for _, cardNum := range cardNumbers {
+			genOpts := GenerateOptions{
+				Inventory: inv,
+				Rand:      rand.New(rand.NewSource(DeriveSeed(seed, int(animal), cardNum, diceNumber))),
+				Progress: func(report GenerateReport) {
+					progress(cardNum, diceNumber, report, false)
+				},
+			}
+			shape := shapeSelector(sourceByNumber[cardNum], diceNumber)
+			gen := newGeneration(bf, shape, height, blockCount, numProblemsPerDiceNum, genOpts)
+
+			// as soon as all cards of a dice number are complete, try to build a set
+			// and store it in the checkpoint
+			gen.onDone = func(gen *generation) {
+				progress(cardNum, diceNumber, gen.report, true)
+				problems[diceNumber][cardNum] = gen.problems
+				if len(problems[diceNumber]) < len(sourceCards) {
+					return
+				}
+
+				r := rand.New(rand.NewSource(DeriveSeed(seed, int(animal), 0, diceNumber)))
+				entry := checkpointEntry{checkpointKeyOf(diceNumber), make(map[int][]int), nil}
+				problemSet, failure := SelectProblemSet(problems[diceNumber], inv, players, r)
+				if failure != nil {
+					failure.DiceNumber = diceNumber
+					entry.Unfilled = failure
+					unfilled[diceNumber] = failure
+				}
 				for cardNum, prob := range problemSet {
 					cardSet[cardNum].Problems[diceNumber] = prob
+					numbers := make([]int, 0, prob.Blocks.Count)
+					for _, b := range prob.Blocks.AsSlice() {
+						numbers = append(numbers, b.Number)
+					}
+					entry.Problems[cardNum] = numbers
 				}
-				break
+				checkpoint.store(entry)
 			}
+			gens = append(gens, gen)
 		}
 	}
+	runGenerations(ctx, opts.Workers, gens)
 
 	// flatten and sort map to array
 	result := make([]*card.C, 0)
@@ -451,46 +636,104 @@ func GenerateCardSet(bc *cardfactory.F, bf *blockfactory.F,
 		return result[i].CardNumber < result[j].CardNumber
 	})
 
+	diceNumbers := make([]int, 0, len(unfilled))
+	for diceNumber := range unfilled {
+		diceNumbers = append(diceNumbers, diceNumber)
+	}
+	sort.Ints(diceNumbers)
+	unfilledList := make([]UnfilledDice, 0, len(unfilled))
+	for _, diceNumber := range diceNumbers {
+		unfilledList = append(unfilledList, *unfilled[diceNumber])
+	}
+
+	if err := ctx.Err(); err != nil {
+		return result, unfilledList, err
+	}
+
 	// write to file
 	if outputFile != "" {
 		f, _ := os.Create(outputFile)
 		defer f.Close()
+		f.WriteString(SeedHeader(seed, inv, players))
 		for _, c := range result {
 			f.WriteString(c.VerbousString())
 		}
 	}
 
-	return result
+	return result, unfilledList, nil
 }
 
-// GenerateProblems creates numProblems new problems based on the given
-// parameters (height, shape, blockCount)
-func GenerateProblems(bf *blockfactory.F, shape *array2d.A, height, blockCount, numProblems int) []*problem.P {
-	if bf == nil || shape == nil {
-		panic("BlockFactory and shape parameters must not be nil")
+// SelectProblemSet chooses one problem per card such that the problems can be played
+// together, see IsPossibleCardSet. All combinations are searched by backtracking, the
+// candidates of each card in random order taken from r (seeded with the current time if nil),
+// so a set is found whenever one exists. problems contains the candidates per card number.
+// Returns the set and nil, or nil and the reason if there is no such set; the DiceNumber of
+// the reason is left to the caller. Without any cards, the reason is NoProblems for card 0
+func SelectProblemSet(problems map[int][]*problem.P, inv *inventory.I, players int,
+	r *rand.Rand) (map[int]*problem.P, *UnfilledDice) {
+	if inv == nil {
+		panic("Inventory must not be nil")
 	}
-	if height < 1 || blockCount < 1 || numProblems < 1 {
-		panic("Height, BlockCount and NumProblems must all be >= 1")
+	if r == nil {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
 	}
 
-	multiplier := 5 // we generate more problems than requested, as some might not have a solution
-	results := make([]*problem.P, 0)
-
-	// generate random blocksets, more than we need, as not all might be solvable
-	sets := bf.GenerateBlocksets(shape.Count(0)*height, blockCount, multiplier*numProblems)
+	// shuffle in the order of the card numbers, so the same r yields the same set
+	cardNumbers := make([]int, 0, len(problems))
+	for cardNum := range problems {
+		cardNumbers = append(cardNumbers, cardNum)
+	}
+	sort.Ints(cardNumbers)
 
-	for i := range sets {
-		p := problem.New(shape, height, sets[i])
-		g := New(p)
-		solutions := g.Solve()
+	counts := make(map[int]int)
+	for cardNum, probs := range problems {
+		counts[cardNum] = len(probs)
+	}
+	if len(cardNumbers) == 0 {
+		return nil, &UnfilledDice{Failure: NoProblems, Candidates: counts}
+	}
 
-		if len(solutions) > 0 {
-			results = append(results, p)
+	candidates := make([][]*problem.P, len(cardNumbers))
+	for i, cardNum := range cardNumbers {
+		if len(problems[cardNum]) == 0 {
+			return nil, &UnfilledDice{Failure: NoProblems, CardNumber: cardNum, Candidates: counts}
 		}
+		candidates[i] = make([]*problem.P, len(problems[cardNum]))
+		copy(candidates[i], problems[cardNum])
+		r.Shuffle(len(candidates[i]), func(a, b int) {
+			candidates[i][a], candidates[i][b] = candidates[i][b], candidates[i][a]
+		})
+	}
 
-		if len(results) >= numProblems {
-			break
+	// a partial set that can't be played can't be completed, as more cards only add blocks
+	problemSet := make(map[int]*problem.P) // key=CardNumber
+	var search func(idx int) bool
+	search = func(idx int) bool {
+		if idx == len(cardNumbers) {
+			return true
 		}
+		for _, p := range candidates[idx] {
+			problemSet[cardNumbers[idx]] = p
+			if IsPossibleCardSet(problemSet, inv, players) && search(idx+1) {
+				return true
+			}
+		}
+		delete(problemSet, cardNumbers[idx])
+		return false
+	}
+
+	if !search(0) {
+		return nil, &UnfilledDice{Failure: NoConsistentSet, Candidates: counts}
 	}
-	return results
+	return problemSet, nil
+}
+
+// GenerateProblems creates numProblems new problems based on the given
+// parameters (height, shape, blockCount). If there are at most exhaustiveLimit
+// blocksets matching the volume, all of them are tested, so problems are found
+// whenever they exist. The random numbers are taken from r, a generator seeded with
+// the current time if nil. Use GenerateProblemsWithOptions to constrain the problems
+func GenerateProblems(bf *blockfactory.F, shape *array2d.A, height, blockCount, numProblems int, r *rand.Rand) []*problem.P {
+	problems, _ := GenerateProblemsWithOptions(context.Background(), bf, shape, height, blockCount, numProblems, GenerateOptions{Rand: r})
+	return problems
 }
@@ -1,9 +1,15 @@
 package game_test
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 	"ubongo/base/array2d"
@@ -16,6 +22,7 @@ import (
 	"ubongo/cardfactory"
 	. "ubongo/game"
 	"ubongo/gamesolution"
+	"ubongo/inventory"
 	"ubongo/problem"
 
 	"github.com/stretchr/testify/assert"
@@ -65,6 +72,14 @@ func TestClone(t *testing.T) {
 	assert.True(t, g.Shape.Equals(c.Shape), "Shape does not match")
 	assert.True(t, g.Volume.Equals(c.Volume), "Volume does not match")
 	assert.True(t, g.Blocks.Equals(c.Blocks), "Block arrays do not match")
+
+	g.Solver = BitmaskSolver
+	g.Pruning = true
+	assert.Equal(t, BitmaskSolver, g.Clone().Solver, "Solver does not match")
+	assert.True(t, g.Clone().Pruning, "Pruning does not match")
+
+	g.Strategy = MostConstrainedCell
+	assert.Equal(t, MostConstrainedCell, g.Clone().Strategy, "Strategy does not match")
 }
 
 func TestTryAddBlock(t *testing.T) {
@@ -126,11 +141,395 @@ func TestSolve(t *testing.T) {
 	assert.Equal(t, 6, len(solutions), "Expected 6 solutions, but found %d", len(solutions))
 }
 
+func TestSolveParallel(t *testing.T) {
+	f := cardfactory.Get()
+	for _, p := range f.GetAllProblems(card.Difficult) {
+		g := New(p)
+		assert.Equal(t, g.Solve(), g.SolveParallel(4), "Parallel solver result differs for problem %s", p)
+	}
+
+	g := New(cardfactory.Get().Get(card.Difficult, 12).Problems[1])
+	g.Solver = BitmaskSolver
+	assert.Equal(t, g.Solve(), g.SolveParallel(0))
+
+	// with MostConstrainedCell, the solutions are returned in the order of BlockOrder
+	for _, p := range f.GetAllProblems(card.Difficult) {
+		g := New(p)
+		expected := g.Solve()
+		g.Strategy = MostConstrainedCell
+		actual := g.SolveParallel(4)
+		assert.Equal(t, expected, actual, "Parallel solver result differs for problem %s", p)
+		assert.ElementsMatch(t, g.Solve(), actual)
+	}
+
+	// symmetric copies found by different workers are removed, too
+	for _, p := range f.GetAllProblems(card.Difficult) {
+		g := New(p)
+		g.Distinct = true
+		assert.Equal(t, g.Solve(), g.SolveParallel(4), "Distinct solutions differ for problem %s", p)
+		g.Strategy = MostConstrainedCell
+		assert.Equal(t, len(g.Solve()), len(g.SolveParallel(4)), "Distinct solution count differs for problem %s", p)
+	}
+
+	var nilGame *G = nil
+	assert.Equal(t, 0, len(nilGame.SolveParallel(2)))
+}
+
+func TestSolveParallelSingleBlock(t *testing.T) {
+	f := blockfactory.Get()
+	p := problem.New(array2d.NewFromData([][]int8{{0, 0}, {0, -1}}), 1, blockset.New(f.Blue_v))
+	g := New(p)
+
+	solutions := g.SolveParallel(2)
+	assert.Equal(t, g.Solve(), solutions)
+	assert.Equal(t, 1, len(solutions))
+}
+
+func TestSolveContext(t *testing.T) {
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	for _, solver := range []SolverType{ArraySolver, BitmaskSolver} {
+		g := New(p)
+		g.Solver = solver
+
+		res := g.SolveContext(context.Background(), SolveOptions{})
+		assert.Equal(t, Complete, res.Status)
+		assert.Equal(t, g.Solve(), res.Solutions)
+		assert.Less(t, 0, res.Nodes)
+		assert.True(t, g.Volume.Equals(p.Shape.Extrude(p.Height)), "Volume changed after solving")
+
+		// with the exact number of nodes, the search can still complete
+		res = g.SolveContext(context.Background(), SolveOptions{MaxNodes: res.Nodes})
+		assert.Equal(t, Complete, res.Status)
+	}
+
+	var nilGame *G = nil
+	assert.Equal(t, 0, len(nilGame.SolveContext(context.Background(), SolveOptions{}).Solutions))
+}
+
+func TestSolveContextLimits(t *testing.T) {
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	for _, solver := range []SolverType{ArraySolver, BitmaskSolver} {
+		g := New(p)
+		g.Solver = solver
+		all := g.Solve()
+
+		res := g.SolveContext(context.Background(), SolveOptions{MaxNodes: 5})
+		assert.Equal(t, Truncated, res.Status)
+		assert.Equal(t, 5, res.Nodes)
+		assert.LessOrEqual(t, len(res.Solutions), len(all))
+		assert.True(t, g.Volume.Equals(p.Shape.Extrude(p.Height)), "Volume changed after truncated search")
+
+		res = g.SolveContext(context.Background(), SolveOptions{MaxDuration: time.Nanosecond})
+		assert.Equal(t, Truncated, res.Status)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		res = g.SolveContext(ctx, SolveOptions{})
+		assert.Equal(t, Cancelled, res.Status)
+		assert.Equal(t, 0, len(res.Solutions))
+	}
+}
+
+func TestSolutions(t *testing.T) {
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	for _, solver := range []SolverType{ArraySolver, BitmaskSolver} {
+		g := New(p)
+		g.Solver = solver
+
+		solutions := make([]*gamesolution.S, 0)
+		for sol := range g.Solutions() {
+			solutions = append(solutions, sol)
+		}
+		assert.Equal(t, g.Solve(), solutions)
+
+		// stop after the first solution
+		count := 0
+		for sol := range g.Solutions() {
+			assert.Equal(t, solutions[0], sol)
+			count++
+			break
+		}
+		assert.Equal(t, 1, count)
+		assert.True(t, g.Volume.Equals(p.Shape.Extrude(p.Height)), "Volume changed after stopping the iteration")
+	}
+
+	var nilGame *G = nil
+	for range nilGame.Solutions() {
+		assert.Fail(t, "nil game must not yield solutions")
+	}
+}
+
+func TestSolutionsContext(t *testing.T) {
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	g := New(p)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range g.SolutionsContext(ctx, SolveOptions{}) {
+		assert.Fail(t, "cancelled search must not yield solutions")
+	}
+
+	count := 0
+	for range g.SolutionsContext(context.Background(), SolveOptions{MaxNodes: 1}) {
+		count++
+	}
+	assert.Equal(t, 0, count)
+
+	// the status tells why the iteration ended
+	solutions, status := g.SolutionsWithStatus(context.Background(), SolveOptions{MaxNodes: 1})
+	assert.Equal(t, Complete, status())
+	for range solutions {
+		assert.Fail(t, "truncated search must not yield solutions")
+	}
+	assert.Equal(t, Truncated, status())
+
+	solutions, status = g.SolutionsWithStatus(ctx, SolveOptions{})
+	for range solutions {
+	}
+	assert.Equal(t, Cancelled, status())
+
+	solutions, status = g.SolutionsWithStatus(context.Background(), SolveOptions{})
+	count = 0
+	for range solutions {
+		count++
+	}
+	assert.Equal(t, len(g.Solve()), count)
+	assert.Equal(t, Complete, status())
+	for range solutions {
+		break
+	}
+	assert.Equal(t, Complete, status())
+}
+
+func TestHasSolution(t *testing.T) {
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	assert.True(t, New(p).HasSolution())
+
+	p = p.Clone()
+	p.Blocks.RemoveAt(3)
+	assert.False(t, New(p).HasSolution())
+
+	var nilGame *G = nil
+	assert.False(t, nilGame.HasSolution())
+}
+
+func TestCountSolutions(t *testing.T) {
+	// this problem has 6 solutions
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	g := New(p)
+	g.Solver = BitmaskSolver
+
+	assert.Equal(t, 6, g.CountSolutions(-1))
+	assert.Equal(t, 6, g.CountSolutions(6))
+	assert.Equal(t, 6, g.CountSolutions(10))
+	assert.Equal(t, 2, g.CountSolutions(1), "Expected limit+1 to tell that the solution is not unique")
+	assert.Equal(t, 1, g.CountSolutions(0))
+
+	// this problem has a unique solution
+	unique := New(cardfactory.Get().Get(card.Easy, 1).Problems[1])
+	assert.Equal(t, 1, unique.CountSolutions(1))
+}
+
+func TestSolveStatusString(t *testing.T) {
+	assert.Equal(t, "Complete", Complete.String())
+	assert.Equal(t, "Truncated", Truncated.String())
+	assert.Equal(t, "Cancelled", Cancelled.String())
+	assert.Equal(t, "Unknown", SolveStatus(-1).String())
+}
+
+func TestSolvePruning(t *testing.T) {
+	f := cardfactory.Get()
+	for _, solver := range []SolverType{ArraySolver, BitmaskSolver} {
+		nodes, prunedNodes := 0, 0
+		for _, p := range f.GetAllProblems(card.Difficult) {
+			g := New(p)
+			g.Solver = solver
+			expected := g.SolveContext(context.Background(), SolveOptions{})
+
+			g.Pruning = true
+			actual := g.SolveContext(context.Background(), SolveOptions{})
+
+			assert.Equal(t, expected.Solutions, actual.Solutions, "Pruning changed the solutions of problem %s", p)
+			assert.LessOrEqual(t, actual.Nodes, expected.Nodes)
+			nodes += expected.Nodes
+			prunedNodes += actual.Nodes
+		}
+		assert.Less(t, prunedNodes, nodes)
+		t.Logf("%s: %d nodes without pruning, %d nodes with pruning (%.1f%%) for all Difficult problems",
+			solver, nodes, prunedNodes, 100*float64(prunedNodes)/float64(nodes))
+	}
+}
+
+func TestSolveMostConstrainedCell(t *testing.T) {
+	f := cardfactory.Get()
+	for _, pruning := range []bool{false, true} {
+		nodes, cellNodes := 0, 0
+		for _, p := range f.GetAllProblems(card.Difficult) {
+			g := New(p)
+			g.Pruning = pruning
+			expected := g.SolveContext(context.Background(), SolveOptions{})
+
+			g.Strategy = MostConstrainedCell
+			actual := g.SolveContext(context.Background(), SolveOptions{})
+
+			// the strategy finds the same solutions, but in a different order
+			assert.ElementsMatch(t, expected.Solutions, actual.Solutions, "Strategy changed the solutions of problem %s", p)
+			nodes += expected.Nodes
+			cellNodes += actual.Nodes
+		}
+		assert.Less(t, cellNodes, nodes)
+		t.Logf("Pruning %v: %d nodes with %s, %d nodes with %s for all Difficult problems",
+			pruning, nodes, BlockOrder, cellNodes, MostConstrainedCell)
+	}
+}
+
+// pieceKey describes the cells covered by each block type, so solutions only
+// differing in the order of identical blocks have the same key
+func pieceKey(sol *gamesolution.S) string {
+	pieces := make([]string, len(sol.Blocks))
+	for i, b := range sol.Blocks {
+		pieces[i] = fmt.Sprint(b.Number, sol.ShapeIndex[i], sol.Shifts[i])
+	}
+	sort.Strings(pieces)
+	return strings.Join(pieces, ";")
+}
+
+func TestSolveMultiset(t *testing.T) {
+	f := blockfactory.Get()
+	p := problem.New(array2d.New(5, 2), 2, blockset.NewMultiset(f.Blue_v, f.Blue_v, f.Green_L, f.Green_L, f.Blue_v, f.Blue_v))
+	expected := New(p).Solve()
+	assert.Equal(t, 944, len(expected))
+
+	// permutations of identical blocks must not be returned as separate solutions
+	keys := make(map[string]bool)
+	for _, sol := range expected {
+		keys[pieceKey(sol)] = true
+	}
+	assert.Equal(t, len(expected), len(keys))
+
+	for _, solver := range []SolverType{ArraySolver, BitmaskSolver} {
+		for _, strategy := range []SearchStrategy{BlockOrder, MostConstrainedCell} {
+			g := New(p)
+			g.Solver = solver
+			g.Strategy = strategy
+			g.Pruning = true
+			assert.ElementsMatch(t, expected, g.Solve(), "Different solutions with %s and %s", solver, strategy)
+		}
+	}
+	assert.Equal(t, expected, New(p).SolveParallel(4))
+}
+
+func TestSolveDistinct(t *testing.T) {
+	// this problem has 6 solutions, which are pairwise symmetric
+	p := cardfactory.Get().Get(card.Easy, 36).Problems[8]
+	g := New(p)
+	all := g.Solve()
+
+	for _, solver := range []SolverType{ArraySolver, BitmaskSolver} {
+		for _, strategy := range []SearchStrategy{BlockOrder, MostConstrainedCell} {
+			g := New(p)
+			g.Solver = solver
+			g.Strategy = strategy
+			g.Distinct = true
+			assert.Equal(t, 3, len(g.Solve()), "Wrong solution count with %s and %s", solver, strategy)
+		}
+	}
+
+	g.Distinct = true
+	distinct := g.Solve()
+	assert.Equal(t, all[0], distinct[0], "Expected the first solution to be kept")
+	assert.True(t, g.Clone().Distinct)
+	assert.Equal(t, 3, g.CountSolutions(-1))
+}
+
+func TestSearchStrategyString(t *testing.T) {
+	assert.Equal(t, "BlockOrder", BlockOrder.String())
+	assert.Equal(t, "MostConstrainedCell", MostConstrainedCell.String())
+	assert.Equal(t, "Unknown", SearchStrategy(-1).String())
+}
+
+func TestSolverTypeString(t *testing.T) {
+	assert.Equal(t, "ArraySolver", ArraySolver.String())
+	assert.Equal(t, "BitmaskSolver", BitmaskSolver.String())
+	assert.Equal(t, "Unknown", SolverType(-1).String())
+}
+
+func TestSolveBitmask(t *testing.T) {
+	f := cardfactory.Get()
+	for _, difficulty := range []card.UbongoDifficulty{card.Easy, card.Difficult} {
+		for _, p := range f.GetAllProblems(difficulty) {
+			g := New(p)
+			expected := g.Solve()
+
+			g.Solver = BitmaskSolver
+			actual := g.Solve()
+
+			assert.Equal(t, expected, actual, "Bitmask solver result differs for problem %s", p)
+		}
+	}
+}
+
+// plate creates a custom block consisting of a single layer of dimX x dimY cubes
+func plate(number, dimX, dimY int) *block.B {
+	base := array3d.New(dimX, dimY, 1)
+	for x := 0; x < dimX; x++ {
+		for y := 0; y < dimY; y++ {
+			base.Set(x, y, 0, 1)
+		}
+	}
+	return &block.B{Number: number, Name: "plate", Shapes: base.CreateRotations(), Volume: dimX * dimY}
+}
+
+func TestSolveCustomBlocks(t *testing.T) {
+	p := problem.New(array2d.New(3, 2), 2, blockset.New(plate(20, 3, 2), plate(21, 3, 2)))
+	expected := New(p).Solve()
+	assert.Less(t, 0, len(expected))
+
+	g := New(p)
+	g.Solver = BitmaskSolver
+	assert.Equal(t, expected, g.Solve())
+	g.Strategy = MostConstrainedCell
+	assert.Equal(t, len(expected), len(g.Solve()))
+}
+
+func TestSolveOversizedVolume(t *testing.T) {
+	// 9x9x2 cells don't fit into a bitmask, the array solver is used instead
+	p := problem.New(array2d.New(9, 9), 2, blockset.New(plate(20, 9, 9), plate(21, 9, 9)))
+	expected := New(p).Solve()
+	assert.Equal(t, 2, len(expected))
+
+	g := New(p)
+	g.Solver = BitmaskSolver
+	assert.Equal(t, expected, g.Solve())
+	g.Strategy = MostConstrainedCell
+	assert.Equal(t, expected, g.Solve())
+	assert.Equal(t, expected, New(p).SolveParallel(2))
+
+	assert.Equal(t, 0, len(New(p).Diagnose(context.Background(), SolveOptions{})))
+	f := Measure(p)
+	assert.Equal(t, 2, f.SolutionCount)
+	assert.Less(t, 0.0, f.Placements)
+	assert.Less(t, 0.0, Rate(p))
+}
+
+func TestSolveBitmaskPartiallyFilled(t *testing.T) {
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	g := New(p)
+	// place the first block of the first solution
+	sol := g.Solve()[0]
+	assert.True(t, g.TryAddBlock(sol.Blocks[0].Shapes[sol.ShapeIndex[0]], sol.Shifts[0]))
+	g.Blocks.RemoveAt(0)
+	expected := g.Solve()
+
+	g.Solver = BitmaskSolver
+	assert.Less(t, 0, len(expected))
+	assert.Equal(t, expected, g.Solve())
+}
+
 func TestCreateSolutionStatistics(t *testing.T) {
 	f := cardfactory.Get()
-	rand.Seed(time.Now().Unix())
-	csvFile := "solution_stats_test_" + strconv.Itoa(rand.Int()) + ".csv"
-	defer os.Remove(csvFile)
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "solution_stats_test.csv")
 
 	stats := CreateSolutionStatistics(f, csvFile)
 
@@ -140,7 +539,28 @@ func TestCreateSolutionStatistics(t *testing.T) {
 	_, err := os.Stat(csvFile)
 	assert.Nil(t, err)
 
-	assert.Panics(t, func() { CreateSolutionStatistics(f, "><?.txt") })
+	// the directory doesn't exist, so the file can't be created
+	assert.Panics(t, func() { CreateSolutionStatistics(f, filepath.Join(dir, "missing", "stats.csv")) })
+}
+
+func TestCreateSolutionStatisticsParallel(t *testing.T) {
+	f := cardfactory.Get()
+	csvFile := filepath.Join(t.TempDir(), "solution_stats_par_test.csv")
+
+	stats := CreateSolutionStatisticsParallel(f, csvFile, 4)
+
+	for _, rec := range stats {
+		assert.Less(t, 0.0, rec.DifficultyScore)
+		assert.True(t, rec.DistinctSolutionCount > 0 || rec.SolutionCount == 0)
+		assert.LessOrEqual(t, rec.DistinctSolutionCount, rec.SolutionCount)
+	}
+
+	// the checked-in statistics were created sequentially
+	expectedData, err := os.ReadFile(filepath.Join("..", "results", "solutions.csv"))
+	assert.Nil(t, err)
+	actualData, err := os.ReadFile(csvFile)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedData, actualData)
 }
 
 func TestIsPossibleCardSet(t *testing.T) {
@@ -153,7 +573,7 @@ func TestIsPossibleCardSet(t *testing.T) {
 		4: problem.New(shape, 2, blockset.New(f.Blue_lighter, f.Yellow_hello)),
 	}
 
-	assert.True(t, IsPossibleCardSet(okProblemsSet))
+	assert.True(t, IsPossibleCardSet(okProblemsSet, inventory.Ubongo(), 4))
 
 	nokProblemsSet := map[int]*problem.P{
 		1: problem.New(shape, 2, blockset.New(f.Blue_bighook, f.Blue_flash)),
@@ -162,27 +582,392 @@ func TestIsPossibleCardSet(t *testing.T) {
 		4: problem.New(shape, 2, blockset.New(f.Blue_lighter, f.Blue_bighook)),
 	}
 
-	assert.False(t, IsPossibleCardSet(nokProblemsSet))
+	assert.False(t, IsPossibleCardSet(nokProblemsSet, inventory.Ubongo(), 4))
 
 	emptyProblemSet := map[int]*problem.P{}
-	assert.False(t, IsPossibleCardSet(emptyProblemSet))
+	assert.False(t, IsPossibleCardSet(emptyProblemSet, inventory.Ubongo(), 4))
 
 	nilProblemSet := map[int]*problem.P{1: nil}
-	assert.False(t, IsPossibleCardSet(nilProblemSet))
+	assert.False(t, IsPossibleCardSet(nilProblemSet, inventory.Ubongo(), 4))
+
+	// every copy of a multiset counts, there are 4 blue v in the game
+	multisetProblemSet := map[int]*problem.P{
+		1: problem.New(shape, 2, blockset.NewMultiset(f.Blue_v, f.Blue_v)),
+		2: problem.New(shape, 2, blockset.NewMultiset(f.Blue_v, f.Blue_v)),
+	}
+	assert.True(t, IsPossibleCardSet(multisetProblemSet, inventory.Ubongo(), 4))
+	multisetProblemSet[3] = problem.New(shape, 2, blockset.NewMultiset(f.Blue_v, f.Green_L))
+	assert.False(t, IsPossibleCardSet(multisetProblemSet, inventory.Ubongo(), 4))
+
+	// every card contains a blue bighook, there are 2 in the game, so only 2 players can play
+	assert.True(t, IsPossibleCardSet(nokProblemsSet, inventory.Ubongo(), 2))
+	assert.False(t, IsPossibleCardSet(nokProblemsSet, inventory.Ubongo(), 3))
+	assert.False(t, IsPossibleCardSet(nokProblemsSet, inventory.Ubongo(), 5))
+
+	// with two boxes there are enough blocks, not with a missing piece
+	twoBoxes := inventory.Ubongo().Combine(inventory.Ubongo())
+	assert.True(t, IsPossibleCardSet(nokProblemsSet, twoBoxes, 4))
+	missing := inventory.Ubongo()
+	missing.Set(f.Yellow_hello.Number, 0)
+	assert.False(t, IsPossibleCardSet(okProblemsSet, missing, 1))
+	delete(okProblemsSet, 4)
+	assert.True(t, IsPossibleCardSet(okProblemsSet, missing, 4))
+
+	assert.Panics(t, func() { IsPossibleCardSet(okProblemsSet, nil, 4) })
+	assert.Panics(t, func() { IsPossibleCardSet(okProblemsSet, inventory.Ubongo(), 0) })
+}
+
+func TestSelectProblemSet(t *testing.T) {
+	f := blockfactory.Get()
+	shape := array2d.New(3, 3)
+
+	// every card has many problems using a blue bighook, of which there are only 2 in the
+	// game, and a single one without, so at least two cards need their rare problem
+	problems := make(map[int][]*problem.P)
+	rare := make(map[int]*problem.P)
+	others := []*block.B{f.Yellow_hello, f.Red_stool, f.Green_T, f.Green_L}
+	for cardNum := 1; cardNum <= 4; cardNum++ {
+		for i := 0; i < 50; i++ {
+			problems[cardNum] = append(problems[cardNum], problem.New(shape, 2, blockset.New(f.Blue_bighook, f.Blue_flash)))
+		}
+		rare[cardNum] = problem.New(shape, 2, blockset.New(others[cardNum-1]))
+		problems[cardNum] = append(problems[cardNum], rare[cardNum])
+	}
+	for seed := int64(0); seed < 5; seed++ {
+		problemSet, failure := SelectProblemSet(problems, inventory.Ubongo(), 4, rand.New(rand.NewSource(seed)))
+		assert.Nil(t, failure)
+		assert.Equal(t, 4, len(problemSet))
+		assert.True(t, IsPossibleCardSet(problemSet, inventory.Ubongo(), 4))
+		rareCount := 0
+		for cardNum, p := range problemSet {
+			if p == rare[cardNum] {
+				rareCount++
+			}
+		}
+		assert.LessOrEqual(t, 2, rareCount)
+	}
+
+	// the same random numbers yield the same set
+	expected, _ := SelectProblemSet(problems, inventory.Ubongo(), 4, rand.New(rand.NewSource(1)))
+	actual, _ := SelectProblemSet(problems, inventory.Ubongo(), 4, rand.New(rand.NewSource(1)))
+	assert.Equal(t, expected, actual)
+
+	// without the rare problems there is no set for 4 players, but for 2
+	for cardNum := range problems {
+		problems[cardNum] = problems[cardNum][:50]
+	}
+	problemSet, failure := SelectProblemSet(problems, inventory.Ubongo(), 4, nil)
+	assert.Nil(t, problemSet)
+	assert.Equal(t, &UnfilledDice{Failure: NoConsistentSet, Candidates: map[int]int{1: 50, 2: 50, 3: 50, 4: 50}}, failure)
+	problemSet, failure = SelectProblemSet(problems, inventory.Ubongo(), 2, nil)
+	assert.Nil(t, failure)
+	assert.Equal(t, 4, len(problemSet))
+
+	// a card without problems
+	problems[3] = nil
+	problemSet, failure = SelectProblemSet(problems, inventory.Ubongo(), 2, nil)
+	assert.Nil(t, problemSet)
+	assert.Equal(t, &UnfilledDice{Failure: NoProblems, CardNumber: 3, Candidates: map[int]int{1: 50, 2: 50, 3: 0, 4: 50}}, failure)
+
+	// no cards at all
+	problemSet, failure = SelectProblemSet(map[int][]*problem.P{}, inventory.Ubongo(), 2, nil)
+	assert.Nil(t, problemSet)
+	assert.Equal(t, &UnfilledDice{Failure: NoProblems, Candidates: map[int]int{}}, failure)
+
+	assert.Panics(t, func() { SelectProblemSet(problems, nil, 4, nil) })
+}
+
+func TestUnfilledDiceString(t *testing.T) {
+	assert.Equal(t, "NoProblems", NoProblems.String())
+	assert.Equal(t, "NoConsistentSet", NoConsistentSet.String())
+	assert.Equal(t, "Unknown", FillFailure(-1).String())
+
+	u := UnfilledDice{DiceNumber: 3, Failure: NoProblems, CardNumber: 7, Candidates: map[int]int{5: 2, 7: 0}}
+	assert.Equal(t, "Dice number 3: no problem with a solution found for card 7", u.String())
+	u = UnfilledDice{DiceNumber: 4, Failure: NoConsistentSet, Candidates: map[int]int{7: 1, 5: 2}}
+	assert.Equal(t, "Dice number 4: no combination of the problems can be played together (candidates: card 5: 2 card 7: 1)", u.String())
 }
 
 func TestGenerateCardSet(t *testing.T) {
 	bf := blockfactory.Get()
 	cf := cardfactory.Get()
 
-	rand.Seed(time.Now().Unix())
-	file := "cardset_test_" + strconv.Itoa(rand.Int()) + ".txt"
-	defer os.Remove(file)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "cardset_test.txt")
 
-	cards := GenerateCardSet(cf, bf, card.Elephant, card.Easy, card.Easy, 2, 3, file)
+	cards, unfilled, err := GenerateCardSet(context.Background(), cf, bf, card.Elephant, card.Easy, card.Easy, 2, 3, CardSetOptions{Seed: 42}, file)
+	assert.Nil(t, err)
 	assert.Equal(t, 4, len(cards))
-	_, err := os.Stat(file)
+	assert.Equal(t, 0, len(unfilled))
+	for _, c := range cards {
+		assert.Equal(t, 10, len(c.Problems))
+	}
+	_, err = os.Stat(file)
 	assert.Nil(t, err)
+
+	// the same seed yields the same file
+	otherFile := filepath.Join(dir, "cardset_other_test.txt")
+	GenerateCardSet(context.Background(), cf, bf, card.Elephant, card.Easy, card.Easy, 2, 3, CardSetOptions{Seed: 42}, otherFile)
+	expected, _ := os.ReadFile(file)
+	actual, _ := os.ReadFile(otherFile)
+	assert.True(t, strings.HasPrefix(string(expected), SeedHeader(42, inventory.Ubongo(), 4)))
+	assert.Equal(t, string(expected), string(actual))
+}
+
+func TestGenerateCardSetCheckpoint(t *testing.T) {
+	bf := blockfactory.Get()
+	cf := cardfactory.Get()
+
+	dir := t.TempDir()
+	checkpointFile := filepath.Join(dir, "checkpoint_test.jsonl")
+	resumedFile := filepath.Join(dir, "checkpoint_resumed_test.jsonl")
+
+	// an uninterrupted run stores all dice numbers
+	checkpoint, err := OpenCheckpoint(checkpointFile)
+	assert.Nil(t, err)
+	_, ok := checkpoint.Seed()
+	assert.False(t, ok)
+	expected, _, _ := GenerateCardSet(context.Background(), cf, bf, card.Elephant, card.Easy, card.Easy, 2, 3, CardSetOptions{Seed: 42, Checkpoint: checkpoint}, "")
+	assert.Nil(t, checkpoint.Err())
+	assert.Equal(t, 10, checkpoint.Len())
+	assert.Nil(t, checkpoint.Close())
+
+	// simulate an interruption after four dice numbers, while writing the fifth
+	content, _ := os.ReadFile(checkpointFile)
+	lines := strings.SplitAfter(string(content), "\n")
+	assert.Nil(t, os.WriteFile(resumedFile, []byte(strings.Join(lines[:4], "")+lines[4][:10]), 0644))
+
+	checkpoint, err = OpenCheckpoint(resumedFile)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, checkpoint.Len())
+	seed, ok := checkpoint.Seed()
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), seed)
+	players, ok := checkpoint.Players()
+	assert.True(t, ok)
+	assert.Equal(t, 4, players)
+	actual, _, _ := GenerateCardSet(context.Background(), cf, bf, card.Elephant, card.Easy, card.Easy, 2, 3, CardSetOptions{Seed: seed, Checkpoint: checkpoint}, "")
+	assert.Equal(t, 10, checkpoint.Len())
+	assert.Nil(t, checkpoint.Close())
+	assert.Equal(t, expected, actual)
+
+	// the resumed file can be read completely again
+	checkpoint, err = OpenCheckpoint(resumedFile)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, checkpoint.Len())
+
+	// different parameters don't use the stored dice numbers
+	GenerateCardSet(context.Background(), cf, bf, card.Elephant, card.Easy, card.Easy, 2, 3, CardSetOptions{Seed: 43, Checkpoint: checkpoint}, "")
+	assert.Equal(t, 20, checkpoint.Len())
+	assert.Nil(t, checkpoint.Close())
+
+	// stored dice numbers are taken from the checkpoint instead of being generated
+	entryOf := func(problems string) string {
+		return fmt.Sprintf(`{"Seed":42,"SourceDifficulty":0,"TargetDifficulty":0,"Animal":0,"Height":2,"BlockCount":3,`+
+			`"Inventory":%q,"Players":4,"DiceNumber":1,"Problems":%s}`, inventory.Ubongo(), problems)
+	}
+	// numbersOf returns the stored representation of the problems of a dice number
+	numbersOf := func(cards []*card.C, diceNumber int) map[int][]int {
+		numbers := make(map[int][]int)
+		for _, c := range cards {
+			for _, b := range c.Problems[diceNumber].Blocks.AsSlice() {
+				numbers[c.CardNumber] = append(numbers[c.CardNumber], b.Number)
+			}
+		}
+		return numbers
+	}
+	marshal := func(numbers map[int][]int) string {
+		data, _ := json.Marshal(numbers)
+		return string(data)
+	}
+
+	// the problems of dice number 2 use the same shapes and can be played together
+	stored := numbersOf(expected, 2)
+	assert.Nil(t, os.WriteFile(resumedFile, []byte(entryOf(marshal(stored))+"\n"), 0644))
+	checkpoint, err = OpenCheckpoint(resumedFile)
+	assert.Nil(t, err)
+	cards, _, _ := GenerateCardSet(context.Background(), cf, bf, card.Elephant, card.Easy, card.Easy, 2, 3, CardSetOptions{Seed: 42, Checkpoint: checkpoint}, "")
+	for i, c := range cards {
+		assert.True(t, expected[i].Problems[2].Blocks.Equals(c.Problems[1].Blocks))
+		assert.Contains(t, c.Problems, 2)
+	}
+	assert.Nil(t, checkpoint.Close())
+
+	// a block of the first card replaced by one with a different volume
+	wrongVolume := numbersOf(expected, 2)
+	for number := bf.MinBlockNumber; number <= bf.MaxBlockNumber; number++ {
+		if bf.ByNumber(number).Volume != bf.ByNumber(wrongVolume[1][0]).Volume {
+			wrongVolume[1][0] = number
+			break
+		}
+	}
+
+	// corrupted entries are generated again: unknown block numbers, wrong block counts, missing cards,
+	// blocks not filling the volume and sets needing more blocks than the inventory contains
+	for _, problems := range []string{
+		`{"1":[1,2,17],"2":[1,2,3],"3":[1,2,3],"4":[1,2,3]}`,
+		`{"1":[1,2],"2":[1,2,3],"3":[1,2,3],"4":[1,2,3]}`,
+		`{"1":[1,2,3],"2":[1,2,3],"3":[1,2,3]}`,
+		`{}`,
+		marshal(wrongVolume),
+		`{"1":[1,2,3],"2":[1,2,3],"3":[1,2,3],"4":[1,2,3]}`,
+	} {
+		assert.Nil(t, os.WriteFile(resumedFile, []byte(entryOf(problems)+"\n"), 0644))
+		checkpoint, err = OpenCheckpoint(resumedFile)
+		assert.Nil(t, err)
+		cards, _, _ := GenerateCardSet(context.Background(), cf, bf, card.Elephant, card.Easy, card.Easy, 2, 3, CardSetOptions{Seed: 42, Checkpoint: checkpoint}, "")
+		for i, c := range cards {
+			assert.True(t, expected[i].Problems[1].Blocks.Equals(c.Problems[1].Blocks), problems)
+		}
+		assert.Nil(t, checkpoint.Close())
+	}
+
+	// dice numbers that couldn't be filled are reported again when resuming
+	entry := fmt.Sprintf(`{"Seed":42,"SourceDifficulty":0,"TargetDifficulty":0,"Animal":0,"Height":2,"BlockCount":3,`+
+		`"Inventory":%q,"Players":4,"DiceNumber":2,"Problems":{},"Unfilled":{"DiceNumber":2,"Failure":1,`+
+		`"CardNumber":0,"Candidates":{"1":3,"2":1,"3":4,"4":2}}}`, inventory.Ubongo())
+	assert.Nil(t, os.WriteFile(resumedFile, []byte(entry+"\n"), 0644))
+	checkpoint, err = OpenCheckpoint(resumedFile)
+	assert.Nil(t, err)
+	cards, unfilled, _ := GenerateCardSet(context.Background(), cf, bf, card.Elephant, card.Easy, card.Easy, 2, 3, CardSetOptions{Seed: 42, Checkpoint: checkpoint}, "")
+	assert.Equal(t, []UnfilledDice{{DiceNumber: 2, Failure: NoConsistentSet, Candidates: map[int]int{1: 3, 2: 1, 3: 4, 4: 2}}}, unfilled)
+	for _, c := range cards {
+		assert.NotContains(t, c.Problems, 2)
+		assert.Contains(t, c.Problems, 1)
+	}
+	assert.Nil(t, checkpoint.Close())
+
+	var nilCheckpoint *Checkpoint
+	assert.Equal(t, 0, nilCheckpoint.Len())
+	_, ok = nilCheckpoint.Players()
+	assert.False(t, ok)
+	assert.Nil(t, nilCheckpoint.Err())
+	assert.Nil(t, nilCheckpoint.Close())
+}
+
+func TestGenerateCardSetProgress(t *testing.T) {
+	bf := blockfactory.Get()
+	cf := cardfactory.Get()
+
+	// every card and dice number reports its completion once
+	done := make(map[[2]int]int)
+	candidates := 0
+	opts := CardSetOptions{Seed: 42, Progress: func(p CardSetProgress) {
+		assert.Equal(t, card.Elephant, p.Animal)
+		assert.LessOrEqual(t, p.Report.Accepted, p.Report.Candidates)
+		if p.Done {
+			done[[2]int{p.CardNumber, p.DiceNumber}]++
+			candidates += p.Report.Candidates
+		}
+	}}
+	cards, _, err := GenerateCardSet(context.Background(), cf, bf, card.Elephant, card.Easy, card.Easy, 2, 3, opts, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(cards))
+	assert.Equal(t, 40, len(done))
+	for _, count := range done {
+		assert.Equal(t, 1, count)
+	}
+	assert.Less(t, 0, candidates)
+
+	// a cancelled generation stops and writes no file
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	file := "cardset_test_" + strconv.Itoa(rand.Int()) + ".txt"
+	cards, _, err = GenerateCardSet(ctx, cf, bf, card.Elephant, card.Easy, card.Easy, 2, 3, CardSetOptions{Seed: 42}, file)
+	assert.Equal(t, context.Canceled, err)
+	for _, c := range cards {
+		assert.Equal(t, 0, len(c.Problems))
+	}
+	_, err = os.Stat(file)
+	assert.True(t, os.IsNotExist(err))
+
+	problems, report := GenerateProblemsWithOptions(ctx, bf, array2d.New(3, 2), 2, 3, 10, GenerateOptions{})
+	assert.Equal(t, 0, len(problems))
+	assert.Equal(t, 0, report.Candidates)
+
+	// cancelling a generation rating the difficulty keeps the outcomes tested completely
+	ctx, cancel = context.WithCancel(context.Background())
+	shape := cf.Get(card.Difficult, 1).Problems[1].Shape
+	rated := GenerateOptions{MinDifficulty: 0.1, Workers: 4, Progress: func(GenerateReport) { cancel() }}
+	problems, report = GenerateProblemsWithOptions(ctx, bf, shape, 3, 6, 100, rated)
+	// the generation stops early, only the candidates tested completely are committed
+	assert.Less(t, 0, report.Candidates)
+	assert.Less(t, len(problems), 100)
+	rejected := 0
+	for _, count := range report.Rejected {
+		rejected += count
+	}
+	assert.Equal(t, report.Candidates, report.Accepted+rejected)
+	assert.Equal(t, report.Accepted, len(problems))
+}
+
+func TestGenerateWorkers(t *testing.T) {
+	bf := blockfactory.Get()
+	cf := cardfactory.Get()
+
+	// the results don't depend on the number of workers
+	expected, _, _ := GenerateCardSet(context.Background(), cf, bf, card.Gnu, card.Easy, card.Easy, 2, 3, CardSetOptions{Seed: 7, Workers: 1}, "")
+	actual, _, _ := GenerateCardSet(context.Background(), cf, bf, card.Gnu, card.Easy, card.Easy, 2, 3, CardSetOptions{Seed: 7, Workers: 7}, "")
+	assert.Equal(t, expected, actual)
+
+	shape := cf.Get(card.Easy, 1).Problems[1].Shape
+	opts := GenerateOptions{MinSolutions: 2, Rand: rand.New(rand.NewSource(3)), Workers: 1}
+	expectedProblems, expectedReport := GenerateProblemsWithOptions(context.Background(), bf, shape, 3, 5, 10, opts)
+	opts.Rand, opts.Workers = rand.New(rand.NewSource(3)), 5
+	actualProblems, actualReport := GenerateProblemsWithOptions(context.Background(), bf, shape, 3, 5, 10, opts)
+	assert.Equal(t, expectedProblems, actualProblems)
+	assert.Equal(t, expectedReport, actualReport)
+}
+
+func TestGenerateCardSetInventory(t *testing.T) {
+	bf := blockfactory.Get()
+	cf := cardfactory.Get()
+
+	// a 2-player game with a box missing all blue v
+	inv := inventory.Ubongo()
+	inv.Set(bf.Blue_v.Number, 0)
+	opts := CardSetOptions{Seed: 42, Inventory: inv, Players: 2}
+	cards, unfilled, err := GenerateCardSet(context.Background(), cf, bf, card.Elephant, card.Easy, card.Easy, 2, 3, opts, "")
+	assert.Nil(t, err)
+	// every dice number is either filled or reported
+	unfilledNumbers := make(map[int]bool)
+	for _, u := range unfilled {
+		unfilledNumbers[u.DiceNumber] = true
+	}
+	for diceNumber := 1; diceNumber <= 10; diceNumber++ {
+		problems := make(map[int]*problem.P)
+		for _, c := range cards {
+			if p, ok := c.Problems[diceNumber]; ok {
+				problems[c.CardNumber] = p
+				assert.Equal(t, 0, p.Blocks.CountOf(bf.Blue_v.Number))
+			}
+		}
+		if unfilledNumbers[diceNumber] {
+			assert.Equal(t, 0, len(problems))
+		} else {
+			assert.Equal(t, 4, len(problems))
+			assert.True(t, IsPossibleCardSet(problems, inv, 2))
+		}
+	}
+
+	problems, _ := GenerateProblemsWithOptions(context.Background(), bf, array2d.New(3, 2), 2, 3, 100, GenerateOptions{Inventory: inv})
+	assert.Less(t, 0, len(problems))
+	for _, p := range problems {
+		assert.True(t, inv.Contains(p.Blocks))
+	}
+
+	assert.Panics(t, func() {
+		GenerateCardSet(context.Background(), cf, bf, card.Elephant, card.Easy, card.Easy, 2, 3, CardSetOptions{Players: -1}, "")
+	})
+}
+
+func TestDeriveSeed(t *testing.T) {
+	assert.Equal(t, DeriveSeed(42, 1, 2, 3), DeriveSeed(42, 1, 2, 3))
+	assert.NotEqual(t, DeriveSeed(42, 1, 2, 3), DeriveSeed(42, 1, 3, 2))
+	assert.NotEqual(t, DeriveSeed(42, 1, 2, 3), DeriveSeed(43, 1, 2, 3))
+	inv := inventory.New(map[int]int{1: 2, 8: 1})
+	assert.Equal(t, "Seed: -7, Players: 3, Inventory: {1: 2, 8: 1}\n", SeedHeader(-7, inv, 3))
+	assert.Equal(t, SeedHeader(-7, inventory.Ubongo(), 3), SeedHeader(-7, nil, 3))
 }
 
 func TestGenerateProblems(t *testing.T) {
@@ -190,10 +975,18 @@ func TestGenerateProblems(t *testing.T) {
 	fb := blockfactory.Get()
 
 	shape := fp.Get(card.Easy, 1).Problems[1].Shape
-	problems := GenerateProblems(fb, shape, 3, 5, 10)
+	problems := GenerateProblems(fb, shape, 3, 5, 10, nil)
 
 	assert.Equal(t, 10, len(problems))
 
+	// the same seed yields the same problems
+	expected := GenerateProblems(fb, shape, 3, 5, 10, rand.New(rand.NewSource(42)))
+	actual := GenerateProblems(fb, shape, 3, 5, 10, rand.New(rand.NewSource(42)))
+	assert.Equal(t, len(expected), len(actual))
+	for i := range expected {
+		assert.Equal(t, expected[i].Blocks.String(), actual[i].Blocks.String())
+	}
+
 	// check that each problem has a solution
 	for _, p := range problems {
 		g := New(p)
@@ -201,3 +994,465 @@ func TestGenerateProblems(t *testing.T) {
 		assert.Less(t, 0, len(solutions))
 	}
 }
+
+func TestGenerateProblemsExhaustive(t *testing.T) {
+	fb := blockfactory.Get()
+	shape := array2d.New(3, 2)
+
+	// the search space is small, so all solvable blocksets without duplicates are found
+	expected := 0
+	records, err := FindBlocksets(context.Background(), fb, shape, 2, 3, inventory.Ubongo(), 0)
+	assert.Nil(t, err)
+	for _, rec := range records {
+		if !rec.Blocks.IsMultiset() {
+			expected++
+		}
+	}
+	problems := GenerateProblems(fb, shape, 2, 3, 1000, nil)
+	assert.Less(t, 0, expected)
+	assert.Equal(t, expected, len(problems))
+}
+
+func TestGenerateProblemsDuplicateBlocks(t *testing.T) {
+	fb := blockfactory.Get()
+	shape := array2d.New(3, 2)
+
+	// take a solvable blockset containing a block twice as the only blocks available
+	records, err := FindBlocksets(context.Background(), fb, shape, 2, 3, inventory.Ubongo(), 0)
+	assert.Nil(t, err)
+	var blocks *blockset.S
+	for _, rec := range records {
+		if rec.Blocks.IsMultiset() {
+			blocks = rec.Blocks
+			break
+		}
+	}
+	assert.NotNil(t, blocks)
+	inv := inventory.New(blocks.Counts())
+
+	problems, _ := GenerateProblemsWithOptions(context.Background(), fb, shape, 2, 3, 10, GenerateOptions{Inventory: inv, Duplicates: true})
+	assert.Equal(t, 1, len(problems))
+	assert.True(t, problems[0].Blocks.IsMultiset())
+	assert.Equal(t, blocks.Counts(), problems[0].Blocks.Counts())
+
+	// without duplicates every block type is used once only
+	problems, _ = GenerateProblemsWithOptions(context.Background(), fb, shape, 2, 3, 10, GenerateOptions{Inventory: inv})
+	assert.Equal(t, 0, len(problems))
+
+	// with a single copy of each block the blockset can't be built
+	for number := range blocks.Counts() {
+		inv.Set(number, 1)
+	}
+	problems, _ = GenerateProblemsWithOptions(context.Background(), fb, shape, 2, 3, 10, GenerateOptions{Inventory: inv, Duplicates: true})
+	assert.Equal(t, 0, len(problems))
+}
+
+func TestGenerateProblemsWithOptions(t *testing.T) {
+	fb := blockfactory.Get()
+	shape := array2d.New(3, 2)
+
+	// unique solutions, the box is symmetric, so no blockset has a unique solution
+	// and all blocksets are tested
+	problems, report := GenerateProblemsWithOptions(context.Background(), fb, shape, 2, 3, 1000, GenerateOptions{MinSolutions: 1, MaxSolutions: 1})
+	assert.Equal(t, 0, len(problems))
+	assert.Equal(t, 0, report.Accepted)
+	rejected := 0
+	for _, count := range report.Rejected {
+		rejected += count
+	}
+	assert.Equal(t, report.Candidates, rejected)
+	assert.Less(t, 0, report.Rejected[TooManySolutions])
+
+	// the shape of the first easy problem has blocksets with a unique solution
+	easyShape := cardfactory.Get().Get(card.Easy, 1).Problems[1].Shape
+	problems, report = GenerateProblemsWithOptions(context.Background(), fb, easyShape, 2, 3, 1000, GenerateOptions{MinSolutions: 1, MaxSolutions: 1})
+	assert.Less(t, 0, len(problems))
+	for _, p := range problems {
+		assert.Equal(t, 1, len(New(p).Solve()))
+	}
+	assert.Equal(t, len(problems), report.Accepted)
+	assert.Equal(t, report.Candidates, report.Accepted+report.Rejected[NoSolution]+report.Rejected[TooManySolutions])
+
+	// solution count range
+	problems, report = GenerateProblemsWithOptions(context.Background(), fb, shape, 2, 3, 1000, GenerateOptions{MinSolutions: 10, MaxSolutions: 20})
+	assert.Less(t, 0, len(problems))
+	for _, p := range problems {
+		count := len(New(p).Solve())
+		assert.GreaterOrEqual(t, count, 10)
+		assert.LessOrEqual(t, count, 20)
+	}
+	assert.Less(t, 0, report.Rejected[TooFewSolutions])
+
+	// blocks and difficulty
+	opts := GenerateOptions{MinNonFlatBlocks: 1, MaxSameColor: 1, MinDifficulty: 0.5, MaxDifficulty: 1.5}
+	problems, report = GenerateProblemsWithOptions(context.Background(), fb, shape, 2, 3, 1000, opts)
+	assert.Less(t, 0, len(problems))
+	for _, p := range problems {
+		assert.Less(t, 0, Measure(p).NonFlatBlocks)
+		colors := make(map[block.BlockColor]int)
+		for _, b := range p.Blocks.AsSlice() {
+			colors[b.Color]++
+			assert.LessOrEqual(t, colors[b.Color], 1)
+		}
+		assert.GreaterOrEqual(t, Rate(p), 0.5)
+		assert.LessOrEqual(t, Rate(p), 1.5)
+	}
+	assert.Less(t, 0, report.Rejected[ColorImbalance])
+	assert.Less(t, 0, report.Rejected[TooFewNonFlatBlocks])
+	assert.Contains(t, report.String(), "Candidates: ")
+
+	assert.Equal(t, "TooManySolutions", TooManySolutions.String())
+	assert.Equal(t, "Unknown", Rejection(-1).String())
+	assert.Panics(t, func() { GenerateProblemsWithOptions(context.Background(), nil, shape, 2, 3, 1, GenerateOptions{}) })
+}
+
+func TestFindBlocksets(t *testing.T) {
+	fb := blockfactory.Get()
+	shape := array2d.New(3, 2)
+	records, err := FindBlocksets(context.Background(), fb, shape, 2, 3, inventory.Ubongo(), 0)
+	assert.Nil(t, err)
+
+	assert.Less(t, 0, len(records))
+	multisets := 0
+	for _, rec := range records {
+		assert.Equal(t, 3, rec.Blocks.Count)
+		assert.Equal(t, 12, rec.Blocks.Volume())
+		assert.Less(t, 0, rec.SolutionCount)
+		assert.Equal(t, len(New(problem.New(shape, 2, rec.Blocks)).Solve()), rec.SolutionCount)
+		for number, count := range rec.Blocks.Counts() {
+			assert.LessOrEqual(t, count, inventory.Ubongo().Count(number))
+		}
+		if rec.Blocks.IsMultiset() {
+			multisets++
+		}
+	}
+	assert.Less(t, 0, multisets, "Expected blocksets with duplicate blocks")
+
+	// a volume that can't be built with 3 blocks
+	empty, err := FindBlocksets(context.Background(), fb, array2d.New(4, 4), 2, 3, inventory.Ubongo(), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(empty))
+
+	// only the blocks of the inventory are used
+	inv := inventory.Ubongo()
+	inv.Set(fb.Blue_v.Number, 0)
+	restricted, err := FindBlocksets(context.Background(), fb, shape, 2, 3, inv, 2)
+	assert.Nil(t, err)
+	assert.Less(t, len(restricted), len(records))
+	for _, rec := range restricted {
+		assert.Equal(t, 0, rec.Blocks.CountOf(fb.Blue_v.Number))
+	}
+
+	// the result doesn't depend on the number of workers
+	sequential, err := FindBlocksets(context.Background(), fb, shape, 2, 3, inventory.Ubongo(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, records, sequential)
+
+	// nothing is solved if the context is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cancelled, err := FindBlocksets(ctx, fb, shape, 2, 3, inventory.Ubongo(), 0)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, len(cancelled))
+
+	assert.Panics(t, func() { FindBlocksets(context.Background(), nil, shape, 2, 3, inventory.Ubongo(), 0) })
+	assert.Panics(t, func() { FindBlocksets(context.Background(), fb, shape, 2, 3, nil, 0) })
+	assert.Panics(t, func() { FindBlocksets(context.Background(), fb, shape, 0, 3, inventory.Ubongo(), 0) })
+}
+
+func TestVerify(t *testing.T) {
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	for _, sol := range New(p).Solve() {
+		assert.Equal(t, 0, len(Verify(p, sol)))
+	}
+	sol := New(p).Solve()[0]
+
+	// moving the first block overlaps another block and leaves cells uncovered
+	moved := gamesolution.New(sol.Blocks, sol.ShapeIndex, sol.Shifts)
+	moved.Shifts[0] = sol.Shifts[1]
+	issues := Verify(p, moved)
+	kinds := make(map[IssueKind]int)
+	for _, issue := range issues {
+		kinds[issue.Kind]++
+	}
+	assert.Less(t, 0, kinds[Uncovered]+kinds[Overlap]+kinds[OutsideShape])
+	assert.Equal(t, kinds[Uncovered], kinds[Overlap]+kinds[OutsideShape], "Every misplaced cell leaves one cell empty")
+
+	// a shift outside the bounding box
+	outside := gamesolution.New(sol.Blocks, sol.ShapeIndex, sol.Shifts)
+	outside.Shifts[2] = vector.V{-1, 0, 0}
+	issues = Verify(p, outside)
+	outsideCount := 0
+	for _, issue := range issues {
+		if issue.Kind == OutsideShape {
+			assert.Equal(t, 2, issue.BlockIdx)
+			assert.Equal(t, sol.Blocks[2].Number, issue.BlockNumber)
+			outsideCount++
+		}
+	}
+	assert.Less(t, 0, outsideCount, "Expected cells at x=-1 to be reported")
+
+	// invalid shape index, the cells of the block remain uncovered
+	invalid := gamesolution.New(sol.Blocks, sol.ShapeIndex, sol.Shifts)
+	invalid.ShapeIndex[1] = 999
+	issues = Verify(p, invalid)
+	assert.Equal(t, Issue{Kind: InvalidShapeIndex, BlockIdx: 1, BlockNumber: sol.Blocks[1].Number}, issues[0])
+	assert.Equal(t, sol.Blocks[1].Volume+1, len(issues))
+
+	// wrong and missing blocks
+	f := blockfactory.Get()
+	blocks := append([]*block.B{}, sol.Blocks...)
+	blocks[3] = f.Blue_v
+	issues = Verify(p, gamesolution.New(blocks, sol.ShapeIndex, sol.Shifts))
+	assert.Equal(t, Issue{Kind: WrongBlock, BlockIdx: 3, BlockNumber: f.Blue_v.Number}, issues[0])
+	assert.Equal(t, Issue{Kind: MissingBlock, BlockIdx: -1, BlockNumber: sol.Blocks[3].Number}, issues[1])
+
+	// malformed solution
+	issues = Verify(p, gamesolution.New(sol.Blocks, sol.ShapeIndex[1:], sol.Shifts))
+	assert.Equal(t, []Issue{{Kind: Malformed, BlockIdx: -1}}, issues)
+
+	// a nil block is malformed and missing, its cells remain uncovered
+	blocks = append([]*block.B{}, sol.Blocks...)
+	blocks[2] = nil
+	issues = Verify(p, gamesolution.New(blocks, sol.ShapeIndex, sol.Shifts))
+	assert.Equal(t, Issue{Kind: Malformed, BlockIdx: 2}, issues[0])
+	assert.Equal(t, Issue{Kind: MissingBlock, BlockIdx: -1, BlockNumber: sol.Blocks[2].Number}, issues[1])
+	assert.Equal(t, sol.Blocks[2].Volume+2, len(issues))
+
+	assert.Panics(t, func() { Verify(nil, sol) })
+	assert.Panics(t, func() { Verify(p, nil) })
+}
+
+func TestIssueString(t *testing.T) {
+	assert.Equal(t, "Overlap: block #1 (number 8) at (0,1,2)", Issue{Kind: Overlap, BlockIdx: 1, BlockNumber: 8, Cell: vector.V{0, 1, 2}}.String())
+	assert.Equal(t, "Uncovered: cell (0,1,2)", Issue{Kind: Uncovered, BlockIdx: -1, Cell: vector.V{0, 1, 2}}.String())
+	assert.Equal(t, "MissingBlock: block number 8", Issue{Kind: MissingBlock, BlockIdx: -1, BlockNumber: 8}.String())
+	assert.Equal(t, "Malformed", Issue{Kind: Malformed, BlockIdx: -1}.String())
+	assert.Equal(t, "Malformed: block #2 is nil", Issue{Kind: Malformed, BlockIdx: 2}.String())
+	assert.Equal(t, "Unknown", IssueKind(99).String())
+}
+
+func TestGetHint(t *testing.T) {
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	sol := New(p).Solve()[0]
+
+	// follow the exact hints from an empty volume to a full solution
+	partial := gamesolution.New([]*block.B{}, []int{}, []vector.V{})
+	for i := 0; i < p.Blocks.Count; i++ {
+		hint := GetHint(p, partial, PlacementHint)
+		assert.True(t, hint.Completable)
+		assert.NotNil(t, hint.Block)
+		assert.True(t, hint.Block.Shapes[hint.ShapeIndex].Get(
+			hint.Cell[0]-hint.Shift[0], hint.Cell[1]-hint.Shift[1], hint.Cell[2]-hint.Shift[2]) == 1)
+		partial = gamesolution.New(append(partial.Blocks, hint.Block),
+			append(partial.ShapeIndex, hint.ShapeIndex), append(partial.Shifts, hint.Shift))
+	}
+	assert.Equal(t, 0, len(Verify(p, partial)))
+	hint := GetHint(p, partial, PlacementHint)
+	assert.True(t, hint.Completable)
+	assert.Nil(t, hint.Block)
+	assert.Equal(t, "Complete", hint.String())
+
+	// lower levels reveal less
+	start := gamesolution.New(sol.Blocks[:1], sol.ShapeIndex[:1], sol.Shifts[:1])
+	hint = GetHint(p, start, BlockHint)
+	assert.True(t, hint.Completable)
+	assert.NotNil(t, hint.Block)
+	assert.Equal(t, -1, hint.ShapeIndex)
+	assert.Equal(t, vector.V{}, hint.Cell)
+	hint = GetHint(p, start, RegionHint)
+	assert.Equal(t, -1, hint.ShapeIndex)
+	assert.True(t, len(hint.String()) > 10)
+
+	// a position of the first block that does not lead to a solution
+	first := p.Blocks.Get(0)
+	found := false
+	for _, shift := range New(p).Volume.GetBoundingBox().GetShiftVectors(first.Shapes[0].GetBoundingBox()) {
+		g := New(p)
+		if !g.TryAddBlock(first.Shapes[0], shift) {
+			continue
+		}
+		g.Blocks.RemoveAt(0)
+		partial := gamesolution.New([]*block.B{first}, []int{0}, []vector.V{shift})
+		hint := GetHint(p, partial, BlockHint)
+		assert.Equal(t, g.HasSolution(), hint.Completable)
+		if !hint.Completable {
+			assert.Nil(t, hint.Block)
+			assert.Equal(t, "Not completable", hint.String())
+			found = true
+		}
+	}
+	assert.True(t, found, "Expected a position without solution")
+
+	// invalid partial solutions
+	f := blockfactory.Get()
+	hint = GetHint(p, gamesolution.New([]*block.B{f.Blue_v}, []int{0}, []vector.V{{0, 0, 0}}), BlockHint)
+	assert.False(t, hint.Completable)
+	assert.False(t, hint.Malformed)
+	assert.False(t, GetHint(p, gamesolution.New(sol.Blocks[:1], sol.ShapeIndex[:1], []vector.V{{-1, 0, 0}}), BlockHint).Completable)
+	assert.False(t, GetHint(p, gamesolution.New(sol.Blocks[:2], sol.ShapeIndex[:2], []vector.V{sol.Shifts[0], sol.Shifts[0]}), BlockHint).Completable)
+
+	// malformed partial solutions are told apart from uncompletable ones
+	for _, malformed := range []*gamesolution.S{
+		gamesolution.New(sol.Blocks[:1], []int{99}, sol.Shifts[:1]),
+		gamesolution.New([]*block.B{nil}, []int{0}, []vector.V{{0, 0, 0}}),
+		gamesolution.New(sol.Blocks[:2], sol.ShapeIndex[:1], sol.Shifts[:2]),
+	} {
+		hint = GetHint(p, malformed, BlockHint)
+		assert.False(t, hint.Completable)
+		assert.True(t, hint.Malformed)
+		assert.Equal(t, "Malformed partial solution", hint.String())
+	}
+
+	// a search stopped by its limits tells so
+	hint = GetHintContext(context.Background(), p, nil, BlockHint, SolveOptions{MaxNodes: 1})
+	assert.False(t, hint.Completable)
+	assert.Equal(t, Truncated, hint.Status)
+	assert.Equal(t, "No completion found (search Truncated)", hint.String())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, Cancelled, GetHintContext(ctx, p, nil, BlockHint, SolveOptions{}).Status)
+	assert.Equal(t, Complete, GetHint(p, nil, BlockHint).Status)
+
+	assert.True(t, GetHint(p, nil, BlockHint).Completable)
+	assert.Panics(t, func() { GetHint(nil, nil, BlockHint) })
+}
+
+func TestHintLevelString(t *testing.T) {
+	assert.Equal(t, "BlockHint", BlockHint.String())
+	assert.Equal(t, "RegionHint", RegionHint.String())
+	assert.Equal(t, "PlacementHint", PlacementHint.String())
+	assert.Equal(t, "Unknown", HintLevel(99).String())
+}
+
+func TestDiagnose(t *testing.T) {
+	f := blockfactory.Get()
+	ctx := context.Background()
+
+	// solvable
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	assert.Equal(t, 0, len(New(p).Diagnose(ctx, SolveOptions{})))
+
+	// volume mismatch
+	p = p.Clone()
+	p.Blocks.RemoveAt(3)
+	explanations := New(p).Diagnose(ctx, SolveOptions{})
+	assert.Equal(t, VolumeMismatch, explanations[0].Reason)
+	assert.Equal(t, p.Area*p.Height, explanations[0].Expected)
+	assert.Equal(t, p.Blocks.Volume(), explanations[0].Actual)
+
+	// T and L don't fit into a 2x2x2 cube
+	g := New(problem.New(array2d.New(2, 2), 2, blockset.New(f.Green_T, f.Green_L)))
+	explanations = g.Diagnose(ctx, SolveOptions{})
+	assert.Equal(t, Explanation{Reason: NoPlacement, BlockNumber: f.Green_T.Number}, explanations[0])
+	assert.Equal(t, Explanation{Reason: NoPlacement, BlockNumber: f.Green_L.Number}, explanations[1])
+	assert.Equal(t, Explanation{Reason: UncoverableCell, Cell: vector.V{0, 0, 0}}, explanations[2])
+	assert.Equal(t, 2+8, len(explanations))
+
+	// the checkerboard colouring of a 3x2x2 box has as many black as white cells,
+	// the flash, T and L can't cover them evenly
+	g = New(problem.New(array2d.New(3, 2), 2, blockset.New(f.Red_flash, f.Green_T, f.Green_L)))
+	explanations = g.Diagnose(ctx, SolveOptions{})
+	assert.Equal(t, 1, len(explanations))
+	assert.Equal(t, ParityViolation, explanations[0].Reason)
+	assert.Equal(t, 0, explanations[0].Expected)
+	assert.NotEqual(t, 0, explanations[0].Actual)
+	assert.Equal(t, 0, len(g.Solve()))
+
+	// no quick check applies, the search fails
+	g = New(problem.New(array2d.New(2, 2), 2, blockset.New(f.Yellow_smallhook, f.Red_smallhook)))
+	explanations = g.Diagnose(ctx, SolveOptions{})
+	assert.Equal(t, 1, len(explanations))
+	assert.Equal(t, SearchExhausted, explanations[0].Reason)
+	assert.Less(t, 0, explanations[0].Nodes)
+
+	explanations = g.Diagnose(ctx, SolveOptions{MaxNodes: 1})
+	assert.Equal(t, Explanation{Reason: Undecided, Nodes: 1}, explanations[0])
+
+	for _, e := range []Explanation{{Reason: VolumeMismatch}, {Reason: NoPlacement}, {Reason: UncoverableCell},
+		{Reason: ParityViolation}, {Reason: SearchExhausted}, {Reason: Undecided}} {
+		assert.True(t, len(e.String()) > 20)
+	}
+	assert.Equal(t, "Unknown", Reason(99).String())
+
+	var nilGame *G = nil
+	assert.Equal(t, 0, len(nilGame.Diagnose(ctx, SolveOptions{})))
+}
+
+func TestMeasure(t *testing.T) {
+	f := blockfactory.Get()
+	p := problem.New(array2d.NewFromData([][]int8{{0, 0, 0}, {0, 0, -1}}), 2, blockset.New(f.Blue_v, f.Green_L, f.Red_smallhook))
+	features := Measure(p)
+
+	assert.Equal(t, 0, features.SolutionCount, "Volume of the blocks doesn't match")
+	assert.Less(t, 1.0, features.Placements)
+	assert.Equal(t, 1, features.NonFlatBlocks, "Only the small hook is not flat")
+	assert.Equal(t, 10, features.Volume)
+	assert.InDelta(t, 5.0/6.0, features.Compactness, 1e-9)
+
+	p = cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	features = Measure(p)
+	assert.Equal(t, 6, features.SolutionCount)
+	assert.Less(t, features.SolutionCount, features.Nodes)
+
+	assert.Panics(t, func() { Measure(nil) })
+}
+
+func TestCalibrate(t *testing.T) {
+	cf := cardfactory.Get()
+	c := Calibrate(cf)
+	assert.InDelta(t, DefaultCalibration.Easy, c.Easy, 1e-3)
+	assert.InDelta(t, DefaultCalibration.Difficult, c.Difficult, 1e-3)
+
+	// the original cards are rated 1 and 2 on average
+	for expected, difficulty := range map[float64]card.UbongoDifficulty{1: card.Easy, 2: card.Difficult} {
+		sum := 0.0
+		problems := cf.GetAllProblems(difficulty)
+		for _, p := range problems {
+			sum += Rate(p)
+		}
+		assert.InDelta(t, expected, sum/float64(len(problems)), 1e-3)
+	}
+
+	// a problem without solution
+	p := cf.Get(card.Difficult, 12).Problems[1].Clone()
+	p.Blocks.RemoveAt(3)
+	assert.Equal(t, 0.0, Rate(p))
+
+	assert.Panics(t, func() { Calibrate(nil) })
+	assert.Panics(t, func() { Calibrate(&cardfactory.F{}) }, "No problems to calibrate with")
+}
+
+// benchmarkSolve solves all Difficult problems with the given configuration
+func benchmarkSolve(b *testing.B, solver SolverType, strategy SearchStrategy, pruning bool) {
+	problems := cardfactory.Get().GetAllProblems(card.Difficult)
+	for i := 0; i < b.N; i++ {
+		for _, p := range problems {
+			g := New(p)
+			g.Solver = solver
+			g.Strategy = strategy
+			g.Pruning = pruning
+			g.Solve()
+		}
+	}
+}
+
+func BenchmarkSolveArrayBlockOrder(b *testing.B) {
+	benchmarkSolve(b, ArraySolver, BlockOrder, false)
+}
+
+func BenchmarkSolveBitmaskBlockOrder(b *testing.B) {
+	benchmarkSolve(b, BitmaskSolver, BlockOrder, false)
+}
+
+func BenchmarkSolveBitmaskBlockOrderPruning(b *testing.B) {
+	benchmarkSolve(b, BitmaskSolver, BlockOrder, true)
+}
+
+func BenchmarkSolveMostConstrainedCell(b *testing.B) {
+	benchmarkSolve(b, BitmaskSolver, MostConstrainedCell, false)
+}
+
+func BenchmarkSolveMostConstrainedCellPruning(b *testing.B) {
+	benchmarkSolve(b, BitmaskSolver, MostConstrainedCell, true)
+}
@@ -0,0 +1,368 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"ubongo/base/array2d"
+	"ubongo/block"
+	"ubongo/blockfactory"
+	"ubongo/card"
+	"ubongo/inventory"
+	"ubongo/problem"
+)
+
+// exhaustiveLimit is the maximum number of blocksets for which the generator
+// tests all of them instead of random samples
+const exhaustiveLimit = 2000
+
+// GenerateOptions constrain the problems created by GenerateProblemsWithOptions.
+// Zero values mean no constraint
+type GenerateOptions struct {
+	// MinSolutions and MaxSolutions limit the number of solutions of a problem,
+	// MinSolutions = MaxSolutions = 1 requests problems with a unique solution
+	MinSolutions, MaxSolutions int
+
+	// MinNonFlatBlocks is the minimum number of blocks that can't be laid flat
+	MinNonFlatBlocks int
+
+	// MaxSameColor is the maximum number of blocks of the same color
+	MaxSameColor int
+
+	// MinDifficulty and MaxDifficulty limit the difficulty score as returned by Rate
+	MinDifficulty, MaxDifficulty float64
+
+	// Candidates is the number of random blocksets tested if there are too many to
+	// test all of them, 0 means five times the number of problems requested
+	Candidates int
+
+	// Rand is the source of the random numbers, the same seed yields the same problems.
+	// If nil, a generator seeded with the current time is used
+	Rand *rand.Rand
+
+	// Progress is called after every candidate tested with the report so far, if not nil.
+	// The calls are made from the goroutine calling GenerateProblemsWithOptions
+	Progress func(GenerateReport)
+
+	// Workers is the number of candidates tested in parallel, 0 means runtime.GOMAXPROCS(0).
+	// The result doesn't depend on it
+	Workers int
+
+	// Inventory contains the blocks available, nil means the original game (inventory.Ubongo).
+	// Every block type of the inventory is used at most once per problem, unless Duplicates is set
+	Inventory *inventory.I
+
+	// Duplicates allows a block type several times per problem, up to its count in the inventory
+	Duplicates bool
+}
+
+// Rejection is an enum describing why a candidate blockset was rejected by the generator
+type Rejection int
+
+// Enumeration values of the Rejection enum
+const (
+	// ColorImbalance means more blocks have the same color than allowed
+	ColorImbalance Rejection = iota
+	// TooFewNonFlatBlocks means less blocks can't be laid flat than required
+	TooFewNonFlatBlocks
+	// NoSolution means the problem can't be solved
+	NoSolution
+	// TooFewSolutions means the problem has less solutions than required
+	TooFewSolutions
+	// TooManySolutions means the problem has more solutions than allowed
+	TooManySolutions
+	// TooEasy means the difficulty score is below the minimum
+	TooEasy
+	// TooDifficult means the difficulty score is above the maximum
+	TooDifficult
+)
+
+// accepted and cancelled are returned by check for a candidate that isn't rejected or
+// whose test was stopped by the context. They are no reasons and therefore not part of
+// the enumeration
+const (
+	accepted  Rejection = -1
+	cancelled Rejection = -2
+)
+
+// String returns a string representation for the Rejection enum
+func (r Rejection) String() string {
+	switch r {
+	case ColorImbalance:
+		return "ColorImbalance"
+	case TooFewNonFlatBlocks:
+		return "TooFewNonFlatBlocks"
+	case NoSolution:
+		return "NoSolution"
+	case TooFewSolutions:
+		return "TooFewSolutions"
+	case TooManySolutions:
+		return "TooManySolutions"
+	case TooEasy:
+		return "TooEasy"
+	case TooDifficult:
+		return "TooDifficult"
+	}
+	return "Unknown"
+}
+
+// GenerateReport tells how many candidate blocksets the generator tested and why they were rejected
+type GenerateReport struct {
+	// Candidates is the number of blocksets tested
+	Candidates int
+
+	// Accepted is the number of problems returned
+	Accepted int
+
+	// Rejected counts the rejected blocksets per reason, each blockset is rejected for
+	// the first reason found only
+	Rejected map[Rejection]int
+}
+
+// String returns a string representation of the report
+func (r GenerateReport) String() string {
+	s := fmt.Sprintf("Candidates: %d, Accepted: %d", r.Candidates, r.Accepted)
+	for reason := ColorImbalance; reason <= TooDifficult; reason++ {
+		if r.Rejected[reason] > 0 {
+			s += fmt.Sprintf(", %s: %d", reason, r.Rejected[reason])
+		}
+	}
+	return s
+}
+
+// GenerateProblemsWithOptions creates up to numProblems new problems based on the given
+// parameters (height, shape, blockCount) that meet the constraints of opts. If there are at
+// most exhaustiveLimit blocksets matching the volume, all of them are tested, otherwise
+// random samples. The candidates are tested on a pool of opts.Workers goroutines.
+// The report tells how many candidates were tested and rejected.
+// If ctx is cancelled, the problems found so far are returned, the candidates tested
+// at that moment are not part of the report
+func GenerateProblemsWithOptions(ctx context.Context, bf *blockfactory.F, shape *array2d.A, height, blockCount, numProblems int,
+	opts GenerateOptions) ([]*problem.P, GenerateReport) {
+	if bf == nil || shape == nil {
+		panic("BlockFactory and shape parameters must not be nil")
+	}
+	if height < 1 || blockCount < 1 || numProblems < 1 {
+		panic("Height, BlockCount and NumProblems must all be >= 1")
+	}
+
+	g := newGeneration(bf, shape, height, blockCount, numProblems, opts)
+	runGenerations(ctx, opts.Workers, []*generation{g})
+	return g.problems, g.report
+}
+
+// clone returns a copy of the report that doesn't share the map of rejections
+func (r GenerateReport) clone() GenerateReport {
+	rejected := make(map[Rejection]int, len(r.Rejected))
+	for reason, count := range r.Rejected {
+		rejected[reason] = count
+	}
+	r.Rejected = rejected
+	return r
+}
+
+// check tests the problem against the options and returns the reason if it is rejected,
+// accepted otherwise. The cheap checks on the blocks are done first, the difficulty score
+// last. Returns cancelled if ctx is cancelled before the test is complete
+func (opts GenerateOptions) check(ctx context.Context, p *problem.P) Rejection {
+	blocks := p.Blocks.AsSlice()
+
+	if opts.MaxSameColor > 0 {
+		colors := make(map[block.BlockColor]int)
+		for _, b := range blocks {
+			colors[b.Color]++
+			if colors[b.Color] > opts.MaxSameColor {
+				return ColorImbalance
+			}
+		}
+	}
+
+	if opts.MinNonFlatBlocks > 0 {
+		nonFlat := 0
+		for _, b := range blocks {
+			if !isFlat(b) {
+				nonFlat++
+			}
+		}
+		if nonFlat < opts.MinNonFlatBlocks {
+			return TooFewNonFlatBlocks
+		}
+	}
+
+	// the difficulty score needs all solutions, which also tell the solution count,
+	// so every candidate is solved once
+	rated := opts.MinDifficulty > 0 || opts.MaxDifficulty > 0
+	var features Features
+	count := 0
+	if rated {
+		var complete bool
+		if features, complete = measureContext(ctx, p); !complete {
+			return cancelled
+		}
+		count = features.SolutionCount
+	} else {
+		g := New(p)
+		g.Solver = g.fastestSolver()
+		g.Pruning = true
+
+		// counting beyond the maximum, or the minimum if there is none, isn't needed
+		limit := max(opts.MinSolutions, 1)
+		if opts.MaxSolutions > 0 {
+			limit = opts.MaxSolutions
+		}
+		solutions, status := g.SolutionsWithStatus(ctx, SolveOptions{})
+		for range solutions {
+			count++
+			if count > limit || count >= limit && opts.MaxSolutions == 0 {
+				break
+			}
+		}
+		if status() != Complete {
+			return cancelled
+		}
+	}
+	if count == 0 {
+		return NoSolution
+	} else if count < opts.MinSolutions {
+		return TooFewSolutions
+	} else if opts.MaxSolutions > 0 && count > opts.MaxSolutions {
+		return TooManySolutions
+	}
+
+	if rated {
+		score := features.Score(DefaultCalibration)
+		if score < opts.MinDifficulty {
+			return TooEasy
+		} else if opts.MaxDifficulty > 0 && score > opts.MaxDifficulty {
+			return TooDifficult
+		}
+	}
+
+	return accepted
+}
+
+// CardSetOptions are the optional parameters of GenerateCardSet
+type CardSetOptions struct {
+	// Seed of the random numbers, the same seed and parameters yield the same cards. Every
+	// problem is generated with a seed derived from Seed, animal, card and dice number
+	Seed int64
+
+	// Checkpoint stores the problems of each completed dice number, dice numbers already
+	// stored are taken from it instead of being generated again. Not used if nil
+	Checkpoint *Checkpoint
+
+	// Progress is called whenever a candidate of a card and dice number was tested, and
+	// when the generation for a card and dice number is done. The calls are made from the
+	// goroutine calling GenerateCardSet
+	Progress func(CardSetProgress)
+
+	// Workers is the number of candidates tested in parallel, 0 means runtime.GOMAXPROCS(0)
+	Workers int
+
+	// Inventory contains the blocks available to play, nil means the original game
+	// (inventory.Ubongo)
+	Inventory *inventory.I
+
+	// Players is the number of players playing a round, each with one card. Any of the
+	// cards of an animal can be played together. 0 means 4 players
+	Players int
+}
+
+// CardSetProgress is the progress of GenerateCardSet for a single card and dice number
+type CardSetProgress struct {
+	Animal     card.UbongoAnimal
+	CardNumber int
+	DiceNumber int
+
+	// Report tells how many candidates were tested so far, Accepted being the solvable ones
+	Report GenerateReport
+
+	// Done is true if the generation of the problems for the card and dice number is finished
+	Done bool
+
+	// Elapsed is the time since the start of GenerateCardSet
+	Elapsed time.Duration
+}
+
+// FillFailure is an enum describing why GenerateCardSet couldn't fill a dice number
+type FillFailure int
+
+// Enumeration values of the FillFailure enum
+const (
+	// NoProblems means no problem with a solution was found for a card
+	NoProblems FillFailure = iota
+	// NoConsistentSet means there are problems for every card, but no combination of
+	// them can be played together with the inventory and number of players
+	NoConsistentSet
+)
+
+// String returns a string representation for the FillFailure enum
+func (f FillFailure) String() string {
+	switch f {
+	case NoProblems:
+		return "NoProblems"
+	case NoConsistentSet:
+		return "NoConsistentSet"
+	}
+	return "Unknown"
+}
+
+// UnfilledDice describes a dice number GenerateCardSet couldn't fill
+type UnfilledDice struct {
+	DiceNumber int
+	Failure    FillFailure
+
+	// CardNumber is the first card without problems, only set for NoProblems
+	CardNumber int
+
+	// Candidates is the number of problems with a solution found per card number
+	Candidates map[int]int
+}
+
+// String returns a human readable description of the unfilled dice number
+func (u UnfilledDice) String() string {
+	switch u.Failure {
+	case NoProblems:
+		return fmt.Sprintf("Dice number %d: no problem with a solution found for card %d", u.DiceNumber, u.CardNumber)
+	case NoConsistentSet:
+		numbers := make([]int, 0, len(u.Candidates))
+		for cardNumber := range u.Candidates {
+			numbers = append(numbers, cardNumber)
+		}
+		sort.Ints(numbers)
+		s := fmt.Sprintf("Dice number %d: no combination of the problems can be played together (candidates:", u.DiceNumber)
+		for _, cardNumber := range numbers {
+			s += fmt.Sprintf(" card %d: %d", cardNumber, u.Candidates[cardNumber])
+		}
+		return s + ")"
+	}
+	return fmt.Sprintf("Dice number %d: %s", u.DiceNumber, u.Failure)
+}
+
+// DeriveSeed combines the seed with the values to a new seed, e.g. to give every problem
+// generated in parallel its own random numbers independent of the order of execution
+func DeriveSeed(seed int64, values ...int) int64 {
+	// mix the values in with the finalizer of the splitmix64 generator
+	x := uint64(seed)
+	for _, v := range values {
+		x ^= uint64(v)
+		x += 0x9e3779b97f4a7c15
+		x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+		x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+		x ^= x >> 31
+	}
+	return int64(x)
+}
+
+// SeedHeader returns the first line of a file of generated cards, recording the seed, the
+// number of players and the inventory needed to generate them again. A nil inventory
+// stands for the original game
+func SeedHeader(seed int64, inv *inventory.I, players int) string {
+	if inv == nil {
+		inv = inventory.Ubongo()
+	}
+	return fmt.Sprintf("Seed: %d, Players: %d, Inventory: %s\n", seed, players, inv)
+}
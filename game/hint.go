@@ -0,0 +1,171 @@
+package game
+
+import (
+	"context"
+	"fmt"
+
+	"ubongo/base/array3d"
+	"ubongo/base/vector"
+	"ubongo/block"
+	"ubongo/gamesolution"
+	"ubongo/problem"
+)
+
+// HintLevel is an enum selecting how much a hint reveals about the next block placement
+type HintLevel int
+
+// Enumeration values of the HintLevel enum
+const (
+	// BlockHint only tells which block to place next
+	BlockHint HintLevel = iota
+	// RegionHint additionally tells one cell of the volume the block covers
+	RegionHint
+	// PlacementHint tells the exact shape and shift of the block
+	PlacementHint
+)
+
+// String returns a string representation for the HintLevel enum
+func (l HintLevel) String() string {
+	switch l {
+	case BlockHint:
+		return "BlockHint"
+	case RegionHint:
+		return "RegionHint"
+	case PlacementHint:
+		return "PlacementHint"
+	}
+	return "Unknown"
+}
+
+// Hint is the answer of GetHint
+type Hint struct {
+	Level HintLevel
+
+	// Completable is true if the partial solution can still be completed to a full solution
+	Completable bool
+
+	// Malformed is true if the partial solution is invalid input: slices of different lengths,
+	// nil blocks or invalid shape indices. Completable is false then
+	Malformed bool
+
+	// Status tells if the search for a completion was complete. If it was stopped early,
+	// Completable is false although the partial solution might be completable
+	Status SolveStatus
+
+	// Block is the block to place next, nil if the partial solution is not completable
+	// or already complete
+	Block *block.B
+
+	// Cell is a cell of the volume covered by the block, only set for RegionHint and PlacementHint
+	Cell vector.V
+
+	// ShapeIndex and Shift define the exact placement of the block, only set for
+	// PlacementHint, otherwise ShapeIndex is -1
+	ShapeIndex int
+	Shift      vector.V
+}
+
+// String returns a string representation of the hint
+func (h Hint) String() string {
+	if h.Malformed {
+		return "Malformed partial solution"
+	} else if !h.Completable && h.Status != Complete {
+		return fmt.Sprintf("No completion found (search %s)", h.Status)
+	} else if !h.Completable {
+		return "Not completable"
+	} else if h.Block == nil {
+		return "Complete"
+	}
+	switch h.Level {
+	case RegionHint:
+		return fmt.Sprintf("Place %s %s at %s", h.Block.Color, h.Block.Name, h.Cell)
+	case PlacementHint:
+		return fmt.Sprintf("Place %s %s with shape #%d at shift %s", h.Block.Color, h.Block.Name, h.ShapeIndex, h.Shift)
+	}
+	return fmt.Sprintf("Place %s %s", h.Block.Color, h.Block.Name)
+}
+
+// GetHint proposes the next block placement for a partially built solution of the problem p.
+// partial contains the blocks already placed, nil if no block was placed yet. The hint is
+// consistent with at least one full solution; how much it reveals is selected by level.
+// A partial solution using blocks not part of the problem, or with overlapping blocks
+// or blocks outside the volume, is not completable. A malformed partial solution is
+// reported by Hint.Malformed
+func GetHint(p *problem.P, partial *gamesolution.S, level HintLevel) Hint {
+	return GetHintContext(context.Background(), p, partial, level, SolveOptions{})
+}
+
+// GetHintContext works like GetHint, but stops the search for a completion early if the
+// context is cancelled or the limits given in opts are reached, see Hint.Status
+func GetHintContext(ctx context.Context, p *problem.P, partial *gamesolution.S, level HintLevel, opts SolveOptions) Hint {
+	if p == nil {
+		panic("Problem must not be nil")
+	}
+	hint := Hint{Level: level, ShapeIndex: -1}
+
+	// place the blocks of the partial solution, the remaining blocks are left in the game
+	g := New(p)
+	if partial != nil {
+		if len(partial.ShapeIndex) != len(partial.Blocks) || len(partial.Shifts) != len(partial.Blocks) {
+			hint.Malformed = true
+			return hint
+		}
+		for i, b := range partial.Blocks {
+			shapeIdx := partial.ShapeIndex[i]
+			shift := partial.Shifts[i]
+			if b == nil || shapeIdx < 0 || shapeIdx >= len(b.Shapes) {
+				hint.Malformed = true
+				return hint
+			}
+			if !g.Blocks.Contains(b.Number) || shift[0] < 0 || shift[1] < 0 || shift[2] < 0 {
+				return hint
+			}
+			if !g.TryAddBlock(b.Shapes[shapeIdx], shift) {
+				return hint
+			}
+			g.Blocks.RemoveOne(b.Number)
+		}
+	}
+	if g.Blocks.Count == 0 {
+		hint.Completable = g.Volume.Count(0) == 0
+		return hint
+	}
+
+	// find one solution for the remaining blocks and propose its first block
+	g.Pruning = true
+	var sol *gamesolution.S
+	solutions, status := g.SolutionsWithStatus(ctx, opts)
+	for s := range solutions {
+		sol = s
+		break
+	}
+	if sol == nil {
+		hint.Status = status()
+		return hint
+	}
+
+	hint.Completable = true
+	hint.Block = sol.Blocks[0]
+	if level >= RegionHint {
+		hint.Cell = firstCell(hint.Block.Shapes[sol.ShapeIndex[0]], sol.Shifts[0])
+	}
+	if level >= PlacementHint {
+		hint.ShapeIndex = sol.ShapeIndex[0]
+		hint.Shift = sol.Shifts[0]
+	}
+	return hint
+}
+
+// firstCell returns the coordinates of the first solid cell of the shifted shape
+func firstCell(shape *array3d.A, shift vector.V) vector.V {
+	for x := 0; x < shape.DimX; x++ {
+		for y := 0; y < shape.DimY; y++ {
+			for z := 0; z < shape.DimZ; z++ {
+				if shape.Get(x, y, z) == 1 {
+					return vector.V{x + shift[0], y + shift[1], z + shift[2]}
+				}
+			}
+		}
+	}
+	return shift
+}
@@ -0,0 +1,60 @@
+package game
+
+import (
+	"context"
+
+	"ubongo/base/array2d"
+	"ubongo/blockfactory"
+	"ubongo/blockset"
+	"ubongo/inventory"
+	"ubongo/problem"
+)
+
+// BlocksetRecord represents a single entry of the output of FindBlocksets
+type BlocksetRecord struct {
+	Blocks        *blockset.S
+	SolutionCount int
+}
+
+// FindBlocksets solves the inverse problem: it returns all combinations of blockCount blocks
+// that fill the volume of the given shape and height, together with their number of solutions.
+// Every block type is used at most as often as it is contained in the inventory.
+// The blocksets are solved in parallel on the given number of workers, the result is always
+// returned in the same order. If workers is smaller than 1, runtime.GOMAXPROCS(0) workers are used.
+// If ctx is cancelled, only the blocksets solved completely so far are returned together
+// with the error of the context
+func FindBlocksets(ctx context.Context, bf *blockfactory.F, shape *array2d.A, height, blockCount int,
+	inv *inventory.I, workers int) ([]BlocksetRecord, error) {
+	if bf == nil || shape == nil || inv == nil {
+		panic("BlockFactory, shape and inventory parameters must not be nil")
+	}
+	if height < 1 || blockCount < 1 {
+		panic("Height and BlockCount must both be >= 1")
+	}
+
+	sets := bf.AllMultisets(shape.Count(0)*height, blockCount, inv.Counts())
+
+	counts := make([]int, len(sets))
+	complete := make([]bool, len(sets))
+	forEachParallel(workers, len(sets), func(i int) {
+		if ctx.Err() != nil {
+			return
+		}
+		g := New(problem.New(shape, height, sets[i]))
+		g.Solver = g.fastestSolver()
+		g.Pruning = true
+		solutions, status := g.SolutionsWithStatus(ctx, SolveOptions{})
+		for range solutions {
+			counts[i]++
+		}
+		complete[i] = status() == Complete
+	})
+
+	records := make([]BlocksetRecord, 0)
+	for i, count := range counts {
+		if complete[i] && count > 0 {
+			records = append(records, BlocksetRecord{sets[i], count})
+		}
+	}
+	return records, ctx.Err()
+}
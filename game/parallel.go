@@ -0,0 +1,93 @@
+package game
+
+import (
+	"ubongo/base/vector"
+	"ubongo/gamesolution"
+	"ubongo/problem"
+	"ubongo/symmetry"
+)
+
+// SolveParallel finds all solutions like Solve, but distributes the work on a pool of
+// workers, each placing the first block at a different position (shape and shift)
+// and solving the remaining blocks on a clone of the game.
+// As the work is split by the placements of the first block, the clones are always solved
+// with the BlockOrder strategy: the solutions are the same as returned by Solve, in the same
+// order with BlockOrder, in the order of BlockOrder with MostConstrainedCell.
+// With Distinct, symmetric copies are removed from the merged solutions of all clones.
+// If workers is smaller than 1, runtime.GOMAXPROCS(0) workers are used
+func (g *G) SolveParallel(workers int) []*gamesolution.S {
+	if g == nil || g.Blocks.Count == 0 {
+		return []*gamesolution.S{}
+	}
+
+	// check the sum of the block volumes, it must match the empty volume of the game to yield a solution
+	if g.Volume.Count(0) != g.Blocks.Volume() {
+		return []*gamesolution.S{}
+	}
+
+	// create one task per placement of the first block, in the order used by the solvers
+	type task struct {
+		shapeIdx int
+		shift    vector.V
+	}
+	tasks := make([]task, 0)
+	gameBox := g.Volume.GetBoundingBox()
+	for shapeIdx, shape := range g.Blocks.Get(0).Shapes {
+		for _, shift := range gameBox.GetShiftVectors(shape.GetBoundingBox()) {
+			tasks = append(tasks, task{shapeIdx, shift})
+		}
+	}
+
+	results := make([][]*gamesolution.S, len(tasks))
+	forEachParallel(workers, len(tasks), func(i int) {
+		results[i] = g.solveWithFirstBlock(tasks[i].shapeIdx, tasks[i].shift)
+	})
+
+	// merge the results in task order
+	solutions := make([]*gamesolution.S, 0)
+	for _, sols := range results {
+		solutions = append(solutions, sols...)
+	}
+
+	// symmetric copies may be found by different tasks, so they are removed after merging
+	if g.Distinct {
+		solutions = symmetry.New(problem.New(g.Shape, g.Volume.DimZ, g.Blocks)).Distinct(solutions)
+	}
+	return solutions
+}
+
+// solveWithFirstBlock solves a clone of the game with the first block fixed at the
+// given shape and shift, returning all solutions including symmetric copies.
+// Called by SolveParallel, don't call directly
+func (g *G) solveWithFirstBlock(shapeIdx int, shift vector.V) []*gamesolution.S {
+	solutions := make([]*gamesolution.S, 0)
+
+	sub := g.Clone()
+	sub.Strategy = BlockOrder
+	sub.Distinct = false
+	if !sub.TryAddBlock(sub.Blocks.Get(0).Shapes[shapeIdx], shift) {
+		return solutions
+	}
+	sub.Blocks.RemoveAt(0)
+
+	// the first block was the only one, no further solving necessary
+	if sub.Blocks.Count == 0 {
+		if sub.Volume.Count(0) == 0 {
+			solutions = append(solutions, gamesolution.New(g.Blocks.AsSlice(), []int{shapeIdx}, []vector.V{shift}))
+		}
+		return solutions
+	}
+
+	// a copy of the first block must be placed behind it, like in the solvers
+	isCopy := sub.Blocks.Get(0).Number == g.Blocks.Get(0).Number
+
+	for _, s := range sub.Solve() {
+		if isCopy && (s.ShapeIndex[0] < shapeIdx || s.ShapeIndex[0] == shapeIdx && !shiftLess(shift, s.Shifts[0])) {
+			continue
+		}
+		shapeIndices := append([]int{shapeIdx}, s.ShapeIndex...)
+		shifts := append([]vector.V{shift}, s.Shifts...)
+		solutions = append(solutions, gamesolution.New(g.Blocks.AsSlice(), shapeIndices, shifts))
+	}
+	return solutions
+}
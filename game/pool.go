@@ -0,0 +1,236 @@
+package game
+
+import (
+	"context"
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+
+	"ubongo/base/array2d"
+	"ubongo/blockfactory"
+	"ubongo/blockset"
+	"ubongo/inventory"
+	"ubongo/problem"
+)
+
+// generation is a batch of candidate blocksets tested by runGenerations, the state
+// of a single call of GenerateProblemsWithOptions
+type generation struct {
+	shape       *array2d.A
+	height      int
+	numProblems int
+	opts        GenerateOptions
+
+	// candidates are tested in parallel, but their outcomes are committed in this order,
+	// so the result doesn't depend on the number of workers
+	candidates []*blockset.S
+	outcomes   []outcome
+
+	// dispatched and committed are the number of candidates handed to the workers and
+	// the number of outcomes processed in order
+	dispatched, committed int
+
+	// acceptedAhead is the number of accepted candidates tested but not yet committed
+	acceptedAhead int
+
+	// problems accepted and the report of the committed candidates
+	problems []*problem.P
+	report   GenerateReport
+
+	// done is set if enough problems were found or all candidates were tested,
+	// onDone is called then if not nil
+	done   bool
+	onDone func(*generation)
+}
+
+// outcome is the result of testing a single candidate
+type outcome struct {
+	tested bool
+	reason Rejection // accepted if the candidate wasn't rejected
+}
+
+// newGeneration chooses the candidate blocksets for the given parameters. If there are at
+// most exhaustiveLimit blocksets matching the volume, all of them in random order, otherwise
+// random samples
+func newGeneration(bf *blockfactory.F, shape *array2d.A, height, blockCount, numProblems int, opts GenerateOptions) *generation {
+	r := opts.Rand
+	if r == nil {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	// use every block type of the inventory once, or as often as it is contained in it
+	// if duplicates are allowed
+	inv := opts.Inventory
+	if inv == nil {
+		inv = inventory.Ubongo()
+	}
+	maxCopies := inv.Counts()
+	if !opts.Duplicates {
+		for number, count := range maxCopies {
+			maxCopies[number] = min(count, 1)
+		}
+	}
+	volume := shape.Count(0) * height
+	sets := bf.AllMultisets(volume, blockCount, maxCopies)
+	if len(sets) <= exhaustiveLimit {
+		r.Shuffle(len(sets), func(i, j int) {
+			sets[i], sets[j] = sets[j], sets[i]
+		})
+	} else {
+		// generate random blocksets, more than we need, as not all might be accepted
+		candidates := opts.Candidates
+		if candidates <= 0 {
+			candidates = 5 * numProblems
+		}
+		sets = bf.GenerateMultisets(volume, blockCount, candidates, maxCopies, r)
+	}
+
+	return &generation{
+		shape:       shape,
+		height:      height,
+		numProblems: numProblems,
+		opts:        opts,
+		candidates:  sets,
+		outcomes:    make([]outcome, len(sets)),
+		problems:    make([]*problem.P, 0),
+		report:      GenerateReport{Rejected: make(map[Rejection]int)}}
+}
+
+// needed returns the number of candidates that may still be needed in addition to the
+// ones dispatched. The candidates being tested may be rejected, so more candidates are
+// tested speculatively until enough accepted ones are known. Outcomes behind the last
+// one needed are dropped, as the generation is done when they arrive
+func (g *generation) needed() int {
+	if g.done {
+		return 0
+	}
+	return min(g.numProblems-len(g.problems)-g.acceptedAhead, len(g.candidates)-g.dispatched)
+}
+
+// record stores the outcome of a candidate and commits the outcomes available in order
+func (g *generation) record(idx int, o outcome) {
+	g.outcomes[idx] = o
+	if o.reason == accepted {
+		g.acceptedAhead++
+	}
+
+	for !g.done && g.committed < g.dispatched && g.outcomes[g.committed].tested {
+		o := g.outcomes[g.committed]
+		g.report.Candidates++
+		if o.reason != accepted {
+			g.report.Rejected[o.reason]++
+		} else {
+			g.acceptedAhead--
+			g.problems = append(g.problems, problem.New(g.shape, g.height, g.candidates[g.committed]))
+			g.report.Accepted++
+		}
+		g.committed++
+		if g.opts.Progress != nil {
+			g.opts.Progress(g.report.clone())
+		}
+		if len(g.problems) >= g.numProblems || g.committed == len(g.candidates) {
+			g.finish()
+		}
+	}
+}
+
+// finish marks the generation as done
+func (g *generation) finish() {
+	g.done = true
+	if g.onDone != nil {
+		g.onDone(g)
+	}
+}
+
+// runGenerations tests the candidates of the generations on a pool of workers, the
+// generations earlier in the slice first. If workers is smaller than 1,
+// runtime.GOMAXPROCS(0) workers are used. The progress and onDone callbacks are called
+// from the goroutine of the caller. Returns when all generations are done or ctx is
+// cancelled, after all workers stopped
+func runGenerations(ctx context.Context, workers int, gens []*generation) {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	type task struct {
+		g   *generation
+		idx int
+		p   *problem.P
+	}
+	type result struct {
+		g   *generation
+		idx int
+		o   outcome
+	}
+
+	// both channels can hold all tasks in flight, so neither side blocks
+	tasks := make(chan task, workers)
+	results := make(chan result, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tasks {
+				results <- result{t.g, t.idx, outcome{tested: true, reason: t.g.opts.check(ctx, t.p)}}
+			}
+		}()
+	}
+
+	for _, g := range gens {
+		if len(g.candidates) == 0 {
+			g.finish()
+		}
+	}
+
+	inFlight := 0
+	for {
+		// hand out candidates to the idle workers
+		for _, g := range gens {
+			for inFlight < workers && ctx.Err() == nil && g.needed() > 0 {
+				tasks <- task{g, g.dispatched, problem.New(g.shape, g.height, g.candidates[g.dispatched])}
+				g.dispatched++
+				inFlight++
+			}
+		}
+		if inFlight == 0 {
+			break
+		}
+
+		res := <-results
+		inFlight--
+		// the outcome is meaningless if the check was cancelled
+		if ctx.Err() == nil && res.o.reason != cancelled && !res.g.done {
+			res.g.record(res.idx, res.o)
+		}
+	}
+
+	close(tasks)
+	wg.Wait()
+}
+
+// forEachParallel calls fn for every index 0..n-1 on a pool of workers and returns when all
+// calls are done. If workers is smaller than 1, runtime.GOMAXPROCS(0) workers are used.
+// The calls are concurrent, so fn must only write to data owned by its index
+func forEachParallel(workers, n int, fn func(i int)) {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+}
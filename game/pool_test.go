@@ -0,0 +1,34 @@
+package game
+
+import (
+	"testing"
+	"ubongo/base/array2d"
+	"ubongo/blockfactory"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerationNeeded(t *testing.T) {
+	g := newGeneration(blockfactory.Get(), array2d.New(3, 2), 2, 3, 1, GenerateOptions{})
+	assert.Less(t, 8, len(g.candidates))
+
+	// candidates being tested may be rejected, so more than the number of problems requested
+	// are dispatched to the workers
+	for i := 0; i < 8; i++ {
+		assert.Less(t, 0, g.needed())
+		g.dispatched++
+	}
+
+	// an accepted candidate ahead of the committed ones covers the problem requested
+	g.record(3, outcome{tested: true, reason: accepted})
+	assert.Equal(t, 0, g.needed())
+
+	// the generation is done once the outcomes before the accepted one are known
+	for _, idx := range []int{0, 1, 2} {
+		assert.False(t, g.done)
+		g.record(idx, outcome{tested: true, reason: NoSolution})
+	}
+	assert.True(t, g.done)
+	assert.Equal(t, 1, len(g.problems))
+	assert.Equal(t, 4, g.report.Candidates)
+}
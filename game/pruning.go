@@ -0,0 +1,145 @@
+package game
+
+import (
+	"ubongo/base/bitmask"
+	"ubongo/blockset"
+	"ubongo/placement"
+)
+
+// pruner implements the dead-space pruning of the solvers: after a block was placed,
+// every connected void of the volume must be fillable with the remaining blocks.
+// Sets of remaining blocks are represented as bitsets, where bit i stands for the
+// i-th block of the blockset
+type pruner struct {
+	// volumes of the blocks, in the order of the blockset
+	volumes []int
+
+	// tables caches the fill tables per set of remaining blocks
+	tables map[uint64]*fillTable
+}
+
+// fillTable lists the void volumes that can be filled with a set of blocks
+type fillTable struct {
+	// sums[v] is true if the volume v can be built with a subset of the blocks
+	sums []bool
+
+	// minVolume is the smallest volume of the blocks
+	minVolume int
+}
+
+// newPruner creates a pruner for the given blocks
+// Panics if the set contains more than 64 blocks
+func newPruner(blocks *blockset.S) *pruner {
+	if blocks.Count > 64 {
+		panic("Pruning supports at most 64 blocks")
+	}
+	p := &pruner{volumes: make([]int, blocks.Count), tables: make(map[uint64]*fillTable)}
+	for i, b := range blocks.AsSlice() {
+		p.volumes[i] = b.Volume
+	}
+	return p
+}
+
+// from returns the set of the blocks k..n-1, i.e. the remaining blocks if the blocks
+// are placed in the order of the blockset
+func (p *pruner) from(k int) uint64 {
+	all := uint64(1)<<len(p.volumes) - 1
+	return all &^ (uint64(1)<<k - 1)
+}
+
+// fits returns true if a void of the given volume can be filled exactly with a subset
+// of the remaining blocks
+func (p *pruner) fits(remaining uint64, volume int) bool {
+	t, ok := p.tables[remaining]
+	if !ok {
+		t = &fillTable{sums: []bool{true}}
+		for i, vol := range p.volumes {
+			if remaining&(1<<i) == 0 {
+				continue
+			}
+			sums := make([]bool, len(t.sums)+vol)
+			for v, ok := range t.sums {
+				if ok {
+					sums[v] = true
+					sums[v+vol] = true
+				}
+			}
+			t.sums = sums
+			if t.minVolume == 0 || vol < t.minVolume {
+				t.minVolume = vol
+			}
+		}
+		p.tables[remaining] = t
+	}
+	return volume >= t.minVolume && volume < len(t.sums) && t.sums[volume]
+}
+
+// hasDeadSpace returns true if the volume of the game contains a void that cannot
+// be filled with the remaining blocks
+func (g *G) hasDeadSpace(p *pruner, remaining uint64) bool {
+	for _, volume := range g.Volume.Components(0) {
+		if !p.fits(remaining, volume) {
+			return true
+		}
+	}
+	return false
+}
+
+// bitNeighbors returns for each cell of the table's bounding box the mask of its face-adjacent cells
+func bitNeighbors(t *placement.T) []bitmask.M {
+	dim := t.Dim
+	neighbors := make([]bitmask.M, dim[0]*dim[1]*dim[2])
+	for x := 0; x < dim[0]; x++ {
+		for y := 0; y < dim[1]; y++ {
+			for z := 0; z < dim[2]; z++ {
+				mask := bitmask.Zero
+				if x > 0 {
+					mask = mask.Set(t.Index(x-1, y, z))
+				}
+				if x < dim[0]-1 {
+					mask = mask.Set(t.Index(x+1, y, z))
+				}
+				if y > 0 {
+					mask = mask.Set(t.Index(x, y-1, z))
+				}
+				if y < dim[1]-1 {
+					mask = mask.Set(t.Index(x, y+1, z))
+				}
+				if z > 0 {
+					mask = mask.Set(t.Index(x, y, z-1))
+				}
+				if z < dim[2]-1 {
+					mask = mask.Set(t.Index(x, y, z+1))
+				}
+				neighbors[t.Index(x, y, z)] = mask
+			}
+		}
+	}
+	return neighbors
+}
+
+// hasBitDeadSpace works like hasDeadSpace for the free cells of the bitmask solver
+func hasBitDeadSpace(free bitmask.M, neighbors []bitmask.M, p *pruner, remaining uint64) bool {
+	rest := free
+	for !rest.IsZero() {
+		// flood-fill the void containing the lowest free cell
+		void := bitmask.Zero.Set(rest.Lowest())
+		frontier := void
+		for !frontier.IsZero() {
+			next := bitmask.Zero
+			for f := frontier; !f.IsZero(); {
+				i := f.Lowest()
+				f = f.Clear(i)
+				next = next.Or(neighbors[i])
+			}
+			frontier = next.And(rest).AndNot(void)
+			void = void.Or(frontier)
+		}
+
+		if !p.fits(remaining, void.Count()) {
+			return true
+		}
+		rest = rest.AndNot(void)
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+package game
+
+import (
+	"context"
+	"iter"
+	"time"
+
+	"ubongo/base/vector"
+	"ubongo/gamesolution"
+	"ubongo/problem"
+	"ubongo/symmetry"
+)
+
+// SolveStatus is an enum telling if a search explored the whole search tree or was stopped early
+type SolveStatus int
+
+// Enumeration values of the SolveStatus enum
+const (
+	// Complete means the whole search tree was explored, all solutions were found
+	Complete SolveStatus = iota
+	// Truncated means the search was stopped because the time or node limit was reached
+	Truncated
+	// Cancelled means the search was stopped because the context was cancelled
+	Cancelled
+)
+
+// String returns a string representation for the SolveStatus enum
+func (s SolveStatus) String() string {
+	switch s {
+	case Complete:
+		return "Complete"
+	case Truncated:
+		return "Truncated"
+	case Cancelled:
+		return "Cancelled"
+	}
+	return "Unknown"
+}
+
+// SolveOptions defines the limits of a search started with SolveContext
+type SolveOptions struct {
+	// MaxDuration is the maximum wall-clock time of the search, 0 means no limit
+	MaxDuration time.Duration
+
+	// MaxNodes is the maximum number of search-tree nodes (blocks placed) to visit, 0 means no limit
+	MaxNodes int
+}
+
+// SolveResult is the outcome of SolveContext
+type SolveResult struct {
+	// Solutions found, this is only a part of all solutions if Status is not Complete
+	Solutions []*gamesolution.S
+
+	// Status tells if the search was complete, truncated or cancelled
+	Status SolveStatus
+
+	// Nodes is the number of search-tree nodes (blocks placed) visited
+	Nodes int
+}
+
+// checkInterval is the number of nodes after which the context and the deadline are checked
+const checkInterval = 1024
+
+// search holds the state of a single run of one of the solvers
+type search struct {
+	ctx      context.Context
+	deadline time.Time
+	maxNodes int
+	nodes    int
+	status   SolveStatus
+
+	// yield is called for every solution found, the search stops if it returns false
+	yield func(*gamesolution.S) bool
+
+	// halted is set if yield requested to stop the search
+	halted bool
+
+	// pruner is used for dead-space pruning, nil if pruning is disabled
+	pruner *pruner
+}
+
+// newSearch creates the state for a search with the given context and options, calling yield
+// for every solution found
+func newSearch(ctx context.Context, opts SolveOptions, yield func(*gamesolution.S) bool) *search {
+	s := &search{ctx: ctx, maxNodes: opts.MaxNodes, status: Complete, yield: yield}
+	if opts.MaxDuration > 0 {
+		s.deadline = time.Now().Add(opts.MaxDuration)
+	}
+	return s
+}
+
+// visit must be called by the solvers before entering a node of the search tree.
+// Returns false if the search must stop, in which case the node must not be entered
+func (s *search) visit() bool {
+	if s.stopped() {
+		return false
+	}
+	if s.maxNodes > 0 && s.nodes >= s.maxNodes {
+		s.status = Truncated
+		return false
+	}
+	if s.nodes%checkInterval == 0 {
+		if s.ctx.Err() != nil {
+			s.status = Cancelled
+			return false
+		}
+		if !s.deadline.IsZero() && time.Now().After(s.deadline) {
+			s.status = Truncated
+			return false
+		}
+	}
+	s.nodes++
+	return true
+}
+
+// stopped returns true if the search must stop
+func (s *search) stopped() bool {
+	return s.halted || s.status != Complete
+}
+
+// found must be called by the solvers for every solution found
+func (s *search) found(sol *gamesolution.S) {
+	if !s.yield(sol) {
+		s.halted = true
+	}
+}
+
+// run executes the solver selected by the game with the given search state
+func (g *G) run(s *search) {
+	if g == nil {
+		return
+	}
+
+	// check the sum of the block volumes, it must match the empty volume of the game to yield a solution
+	if g.Volume.Count(0) != g.Blocks.Volume() {
+		return
+	}
+
+	if g.Pruning {
+		s.pruner = newPruner(g.Blocks)
+	}
+
+	// skip solutions that are symmetric copies of solutions already found
+	if g.Distinct {
+		group := symmetry.New(problem.New(g.Shape, g.Volume.DimZ, g.Blocks))
+		seen := make(map[string]bool)
+		yield := s.yield
+		s.yield = func(sol *gamesolution.S) bool {
+			key := group.Key(sol)
+			if seen[key] {
+				return true
+			}
+			seen[key] = true
+			return yield(sol)
+		}
+	}
+
+	// volumes too big for a bitmask are always solved by the array solver
+	if g.Strategy == MostConstrainedCell && g.fitsBitmask() {
+		g.solveMostConstrainedCell(s)
+	} else if g.Solver == BitmaskSolver && g.fitsBitmask() {
+		g.solveBitmask(s)
+	} else if g.Blocks.Count > 0 {
+		// working arrays for the recursive solver:
+		shapeIdx := make([]int, 0)
+		shifts := make([]vector.V, 0)
+
+		g.recursiveSolver(0, &shapeIdx, &shifts, s)
+	}
+}
+
+// SolveContext finds the solutions of the game like Solve, but stops early if the context
+// is cancelled or the limits given in opts are reached. The solutions found so far are
+// returned together with the status of the search
+func (g *G) SolveContext(ctx context.Context, opts SolveOptions) SolveResult {
+	solutions := make([]*gamesolution.S, 0)
+	s := newSearch(ctx, opts, func(sol *gamesolution.S) bool {
+		solutions = append(solutions, sol)
+		return true
+	})
+
+	g.run(s)
+
+	return SolveResult{solutions, s.status, s.nodes}
+}
+
+// Solutions returns an iterator over all solutions of the game. The solutions are yielded
+// in the same order as returned by Solve, as soon as the solver finds them. The caller can
+// stop the iteration at any time, e.g. after the first solution.
+// The game must not be modified while iterating
+func (g *G) Solutions() iter.Seq[*gamesolution.S] {
+	return g.SolutionsContext(context.Background(), SolveOptions{})
+}
+
+// SolutionsContext works like Solutions, but ends the iteration early if the context
+// is cancelled or the limits given in opts are reached. Use SolutionsWithStatus to tell
+// an early end from a complete search
+func (g *G) SolutionsContext(ctx context.Context, opts SolveOptions) iter.Seq[*gamesolution.S] {
+	solutions, _ := g.SolutionsWithStatus(ctx, opts)
+	return solutions
+}
+
+// SolutionsWithStatus works like SolutionsContext, but additionally returns a function
+// telling the status of the last iteration once it ended. The status is Complete if the
+// caller stopped the iteration or it hasn't been run yet
+func (g *G) SolutionsWithStatus(ctx context.Context, opts SolveOptions) (iter.Seq[*gamesolution.S], func() SolveStatus) {
+	status := Complete
+	solutions := func(yield func(*gamesolution.S) bool) {
+		s := newSearch(ctx, opts, yield)
+		g.run(s)
+		status = s.status
+	}
+	return solutions, func() SolveStatus { return status }
+}
+
+// HasSolution returns true if the game has at least one solution. The search
+// stops as soon as the first solution is found
+func (g *G) HasSolution() bool {
+	for range g.Solutions() {
+		return true
+	}
+	return false
+}
+
+// CountSolutions counts the solutions of the game, but stops the search as soon as
+// limit+1 solutions are found. A result bigger than limit therefore means "more than limit",
+// e.g. CountSolutions(1) == 1 tells that the solution is unique.
+// A negative limit counts all solutions
+func (g *G) CountSolutions(limit int) int {
+	count := 0
+	for range g.Solutions() {
+		count++
+		if limit >= 0 && count > limit {
+			break
+		}
+	}
+	return count
+}
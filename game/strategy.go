@@ -0,0 +1,153 @@
+package game
+
+import (
+	"ubongo/base/bitmask"
+)
+
+// SearchStrategy is an enum selecting the order in which the solvers explore the search tree
+type SearchStrategy int
+
+// Enumeration values of the SearchStrategy enum
+const (
+	// BlockOrder places the blocks in the order of the blockset, trying every
+	// shape and shift of a block before continuing with the next block
+	BlockOrder SearchStrategy = iota
+	// MostConstrainedCell chooses the empty cell with the fewest candidate placements
+	// (across all remaining blocks) and branches on the placements covering it
+	MostConstrainedCell
+)
+
+// String returns a string representation for the SearchStrategy enum
+func (s SearchStrategy) String() string {
+	switch s {
+	case BlockOrder:
+		return "BlockOrder"
+	case MostConstrainedCell:
+		return "MostConstrainedCell"
+	}
+	return "Unknown"
+}
+
+// cellState contains the precomputed data and working arrays of the most-constrained-cell search
+type cellState struct {
+	bitState
+
+	// cellPlacements lists for each block and cell the indices of the placements covering the cell
+	cellPlacements [][][]int
+
+	// remaining is the set of blocks not yet placed, bit i stands for block i
+	remaining uint64
+}
+
+// isCandidate returns true if the block is not placed yet. Of several identical blocks only the
+// first remaining one is a candidate, so permutations of them aren't enumerated
+func (state *cellState) isCandidate(blockIdx int) bool {
+	if state.remaining&(1<<blockIdx) == 0 {
+		return false
+	}
+	return !state.copyOfPrevious[blockIdx] || state.remaining&(1<<(blockIdx-1)) == 0
+}
+
+// solveMostConstrainedCell is the implementation of SolveContext for the MostConstrainedCell
+// strategy. It always works on the bitmask representation of the volume, independent of the
+// solver selected. The solutions are found in a different order than with BlockOrder
+func (g *G) solveMostConstrainedCell(s *search) {
+	if g.Blocks.Count == 0 {
+		return
+	}
+	if g.Blocks.Count > 64 {
+		panic("The MostConstrainedCell strategy supports at most 64 blocks")
+	}
+
+	t := g.placementTable()
+	free := g.freeMask(t)
+	state := &cellState{
+		bitState: bitState{
+			placements:     g.bitPlacements(t, free),
+			chosen:         make([]int, g.Blocks.Count),
+			copyOfPrevious: g.copyOfPrevious()},
+		remaining: uint64(1)<<g.Blocks.Count - 1,
+	}
+	if s.pruner != nil {
+		state.neighbors = bitNeighbors(t)
+	}
+
+	// index the placements by the cells they cover
+	state.cellPlacements = make([][][]int, g.Blocks.Count)
+	for blockIdx, placements := range state.placements {
+		state.cellPlacements[blockIdx] = make([][]int, bitmask.Size)
+		for i, pl := range placements {
+			for m := pl.Mask; !m.IsZero(); {
+				cell := m.Lowest()
+				m = m.Clear(cell)
+				state.cellPlacements[blockIdx][cell] = append(state.cellPlacements[blockIdx][cell], i)
+			}
+		}
+	}
+
+	g.cellSolver(free, state, s)
+}
+
+// cellSolver is the recursive part of solveMostConstrainedCell, don't call directly
+func (g *G) cellSolver(free bitmask.M, state *cellState, s *search) {
+	if state.remaining == 0 {
+		if free.IsZero() {
+			s.found(g.bitSolution(state.placements, state.chosen))
+		}
+		return
+	}
+
+	// find the free cell with the fewest placements covering it
+	bestCell, bestCount := -1, 0
+	for f := free; !f.IsZero(); {
+		cell := f.Lowest()
+		f = f.Clear(cell)
+
+		count := 0
+		for blockIdx := range state.placements {
+			if !state.isCandidate(blockIdx) {
+				continue
+			}
+			for _, i := range state.cellPlacements[blockIdx][cell] {
+				if state.placements[blockIdx][i].Mask.AndNot(free).IsZero() {
+					count++
+				}
+			}
+		}
+		if bestCell < 0 || count < bestCount {
+			bestCell, bestCount = cell, count
+		}
+		if count == 0 {
+			return // this cell can't be covered anymore
+		}
+	}
+	if bestCell < 0 {
+		return // volume is full, but blocks are left
+	}
+
+	// branch on all placements covering the chosen cell
+	for blockIdx := range state.placements {
+		if !state.isCandidate(blockIdx) {
+			continue
+		}
+		for _, i := range state.cellPlacements[blockIdx][bestCell] {
+			if s.stopped() {
+				return
+			}
+			pl := state.placements[blockIdx][i]
+			if !pl.Mask.AndNot(free).IsZero() || !s.visit() {
+				continue
+			}
+
+			state.chosen[blockIdx] = i
+			state.remaining &^= 1 << blockIdx
+			remaining := free.AndNot(pl.Mask)
+
+			if s.pruner == nil || !hasBitDeadSpace(remaining, state.neighbors, s.pruner, state.remaining) {
+				g.cellSolver(remaining, state, s)
+			}
+
+			state.remaining |= 1 << blockIdx
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package game
+
+import (
+	"fmt"
+
+	"ubongo/base/vector"
+	"ubongo/gamesolution"
+	"ubongo/problem"
+)
+
+// IssueKind is an enum describing what is wrong with a solution checked by Verify
+type IssueKind int
+
+// Enumeration values of the IssueKind enum
+const (
+	// Malformed means the slices of the solution have different lengths or a block is nil
+	Malformed IssueKind = iota
+	// WrongBlock means the solution uses a block that is not part of the problem's blockset
+	WrongBlock
+	// MissingBlock means a block of the problem's blockset is not used by the solution
+	MissingBlock
+	// InvalidShapeIndex means the shape index of a block doesn't exist
+	InvalidShapeIndex
+	// OutsideShape means a cell of a block lies outside the volume of the problem
+	OutsideShape
+	// Overlap means a cell is occupied by more than one block
+	Overlap
+	// Uncovered means a cell of the volume is not occupied by any block
+	Uncovered
+)
+
+// String returns a string representation for the IssueKind enum
+func (k IssueKind) String() string {
+	switch k {
+	case Malformed:
+		return "Malformed"
+	case WrongBlock:
+		return "WrongBlock"
+	case MissingBlock:
+		return "MissingBlock"
+	case InvalidShapeIndex:
+		return "InvalidShapeIndex"
+	case OutsideShape:
+		return "OutsideShape"
+	case Overlap:
+		return "Overlap"
+	case Uncovered:
+		return "Uncovered"
+	}
+	return "Unknown"
+}
+
+// Issue is a single error found by Verify
+type Issue struct {
+	Kind IssueKind
+
+	// BlockIdx is the index of the block in the solution, -1 if the issue is not caused by a block
+	// of the solution (Malformed slices, MissingBlock, Uncovered)
+	BlockIdx int
+
+	// BlockNumber is the number of the block concerned, 0 if the issue is not related to a block
+	BlockNumber int
+
+	// Cell contains the coordinates of the cell in the volume, only set for OutsideShape,
+	// Overlap and Uncovered
+	Cell vector.V
+}
+
+// String returns a string representation of the issue
+func (i Issue) String() string {
+	switch i.Kind {
+	case OutsideShape, Overlap:
+		return fmt.Sprintf("%s: block #%d (number %d) at %s", i.Kind, i.BlockIdx, i.BlockNumber, i.Cell)
+	case Uncovered:
+		return fmt.Sprintf("%s: cell %s", i.Kind, i.Cell)
+	case WrongBlock, InvalidShapeIndex:
+		return fmt.Sprintf("%s: block #%d (number %d)", i.Kind, i.BlockIdx, i.BlockNumber)
+	case MissingBlock:
+		return fmt.Sprintf("%s: block number %d", i.Kind, i.BlockNumber)
+	case Malformed:
+		if i.BlockIdx >= 0 {
+			return fmt.Sprintf("%s: block #%d is nil", i.Kind, i.BlockIdx)
+		}
+	}
+	return i.Kind.String()
+}
+
+// Verify checks if sol is a valid solution of the problem p and returns all issues found,
+// an empty slice if the solution is valid. The issues of the blocks are reported in the
+// order of the blocks of the solution, the uncovered cells in the order of their coordinates.
+// Panics if p or sol is nil
+func Verify(p *problem.P, sol *gamesolution.S) []Issue {
+	if p == nil || sol == nil {
+		panic("Problem and solution must not be nil")
+	}
+
+	issues := make([]Issue, 0)
+	if len(sol.ShapeIndex) != len(sol.Blocks) || len(sol.Shifts) != len(sol.Blocks) {
+		return append(issues, Issue{Kind: Malformed, BlockIdx: -1})
+	}
+
+	// compare the blocks used with the blockset, counting copies
+	unused := p.Blocks.Counts()
+	for blockIdx, b := range sol.Blocks {
+		if b == nil {
+			issues = append(issues, Issue{Kind: Malformed, BlockIdx: blockIdx})
+		} else if unused[b.Number] > 0 {
+			unused[b.Number]--
+		} else {
+			issues = append(issues, Issue{Kind: WrongBlock, BlockIdx: blockIdx, BlockNumber: b.Number})
+		}
+	}
+	for _, b := range p.Blocks.AsSlice() {
+		if unused[b.Number] > 0 {
+			unused[b.Number]--
+			issues = append(issues, Issue{Kind: MissingBlock, BlockIdx: -1, BlockNumber: b.Number})
+		}
+	}
+
+	// place the blocks, remembering the occupied cells
+	volume := p.Shape.Extrude(p.Height)
+	occupied := make(map[vector.V]bool)
+	for blockIdx, b := range sol.Blocks {
+		if b == nil {
+			continue
+		}
+		shapeIdx := sol.ShapeIndex[blockIdx]
+		if shapeIdx < 0 || shapeIdx >= len(b.Shapes) {
+			issues = append(issues, Issue{Kind: InvalidShapeIndex, BlockIdx: blockIdx, BlockNumber: b.Number})
+			continue
+		}
+		shape := b.Shapes[shapeIdx]
+		shift := sol.Shifts[blockIdx]
+		for x := 0; x < shape.DimX; x++ {
+			for y := 0; y < shape.DimY; y++ {
+				for z := 0; z < shape.DimZ; z++ {
+					if shape.Get(x, y, z) != 1 {
+						continue
+					}
+					cell := vector.V{x + shift[0], y + shift[1], z + shift[2]}
+					if cell[0] < 0 || cell[0] >= volume.DimX ||
+						cell[1] < 0 || cell[1] >= volume.DimY ||
+						cell[2] < 0 || cell[2] >= volume.DimZ ||
+						volume.Get(cell[0], cell[1], cell[2]) != 0 {
+						issues = append(issues, Issue{Kind: OutsideShape, BlockIdx: blockIdx, BlockNumber: b.Number, Cell: cell})
+					} else if occupied[cell] {
+						issues = append(issues, Issue{Kind: Overlap, BlockIdx: blockIdx, BlockNumber: b.Number, Cell: cell})
+					} else {
+						occupied[cell] = true
+					}
+				}
+			}
+		}
+	}
+
+	// report the cells of the volume that remained empty
+	for x := 0; x < volume.DimX; x++ {
+		for y := 0; y < volume.DimY; y++ {
+			for z := 0; z < volume.DimZ; z++ {
+				if !occupied[vector.V{x, y, z}] && volume.Get(x, y, z) == 0 {
+					issues = append(issues, Issue{Kind: Uncovered, BlockIdx: -1, Cell: vector.V{x, y, z}})
+				}
+			}
+		}
+	}
+
+	return issues
+}
@@ -17,6 +17,7 @@ import (
 	"fyne.io/fyne/v2/canvas"
 	"github.com/tidwall/pinhole"
 
+	"ubongo/assembly"
 	"ubongo/base/array3d"
 	"ubongo/base/vectorf"
 	"ubongo/block"
@@ -42,7 +43,7 @@ func convertCoords(x1, y1, z1, x2, y2, z2 int, offset vectorf.V, maxDim float64)
 }
 
 // drawBlock draws the given block at the given position to the pinhole object
-func drawBlock(pn *pinhole.Pinhole, blockShape *array3d.A, blockColor block.BlockColor, pos vectorf.V, maxDim float64) {
+func drawBlock(pn *pinhole.Pinhole, blockShape *array3d.A, blockColor color.RGBA, pos vectorf.V, maxDim float64) {
 
 	// implements the logical function that decides if an edge should be
 	// shown based on the presence of a block at the two adjacient and the
@@ -152,7 +153,7 @@ func drawBlock(pn *pinhole.Pinhole, blockShape *array3d.A, blockColor block.Bloc
 		}
 	}
 
-	pn.Colorize(blockColor.ToRGBA())
+	pn.Colorize(blockColor)
 
 	pn.End()
 }
@@ -166,7 +167,7 @@ func RenderBlock(block *block.B, width, height int, rx, ry, rz float64) *image.R
 	maxDim := float64(bb.Max())
 	offset := shape.GetCenterOfGravity().Flip()
 
-	drawBlock(pn, shape, block.Color, offset, maxDim)
+	drawBlock(pn, shape, block.Color.ToRGBA(), offset, maxDim)
 
 	pn.Translate(0, 0, 0)
 	pn.Rotate(rx, ry, rz)
@@ -212,7 +213,7 @@ func RenderSolution(gs *gamesolution.S, width, height int, rx, ry, rz, explode f
 		pos := gs.Shifts[i].AsVectorf().Sub(gameCog)
 		explodeOffset := pos.Sub(gameCog).Mult(explode)
 
-		drawBlock(pn, shape, block.Color, pos.Add(explodeOffset), maxDim)
+		drawBlock(pn, shape, block.Color.ToRGBA(), pos.Add(explodeOffset), maxDim)
 	}
 
 	pn.Translate(0, 0, 0)
@@ -227,6 +228,54 @@ func RenderSolution(gs *gamesolution.S, width, height int, rx, ry, rz, explode f
 	return pn.Image(width, height, &opt)
 }
 
+// RenderStep creates an image of the given step of an assembly plan, showing the blocks placed
+// in the previous steps dimmed and the block placed in this step in its color. The view is the
+// same for all steps of a plan. The rotation can be given with the rx, ry, rz parameters
+func RenderStep(plan *assembly.P, step assembly.Step, width, height int, rx, ry, rz float64) *image.RGBA {
+	pn := pinhole.New()
+
+	gs := plan.Solution
+	gameCog := gs.GetCenterOfGravity()
+	bb := gs.GetBoundingBox()
+	maxDim := float64(bb.Max())
+
+	draw := func(blockIdx int, c color.RGBA) {
+		shape := gs.Blocks[blockIdx].Shapes[gs.ShapeIndex[blockIdx]]
+		drawBlock(pn, shape, c, gs.Shifts[blockIdx].AsVectorf().Sub(gameCog), maxDim)
+	}
+	for _, blockIdx := range step.Placed {
+		c := gs.Blocks[blockIdx].Color.ToRGBA()
+		draw(blockIdx, color.RGBA{c.R / 4, c.G / 4, c.B / 4, c.A})
+	}
+	draw(step.BlockIdx, gs.Blocks[step.BlockIdx].Color.ToRGBA())
+
+	pn.Translate(0, 0, 0)
+	pn.Rotate(rx, ry, rz)
+
+	opt := pinhole.ImageOptions{
+		BGColor:   color.Black,
+		LineWidth: 1.0,
+		Scale:     0.9}
+
+	return pn.Image(width, height, &opt)
+}
+
+// RenderPlan renders all steps of an assembly plan to an image each, storing it in the given path
+// returns a list of filenames, ordered by step
+func RenderPlan(plan *assembly.P, dir string, width, height int) []string {
+	files := make([]string, 0)
+	for _, step := range plan.Steps() {
+		img := RenderStep(plan, step, width, height, math.Pi/4, -math.Pi/8, 0)
+		filename := fmt.Sprintf("step_%02d.png", step.Number)
+		fullpath := path.Join(dir, filename)
+		err := SaveAsPng(img, fullpath)
+		if err == nil {
+			files = append(files, fullpath)
+		}
+	}
+	return files
+}
+
 // SaveAsPng save the given image as png-file to disk
 func SaveAsPng(img image.Image, path string) error {
 	file, err := os.Create(path)
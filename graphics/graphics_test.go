@@ -4,6 +4,7 @@ import (
 	"image"
 	"os"
 	"testing"
+	"ubongo/assembly"
 	"ubongo/blockfactory"
 	"ubongo/card"
 	"ubongo/cardfactory"
@@ -58,6 +59,36 @@ func TestRenderAll(t *testing.T) {
 	}
 }
 
+func TestRenderPlan(t *testing.T) {
+	p := cardfactory.Get().Get(card.Difficult, 12).Problems[1]
+	plan := assembly.New(game.New(p).Solve()[0])
+	dir, _ := os.MkdirTemp("./", "testing*")
+	defer os.RemoveAll(dir)
+
+	width := 400
+	height := 300
+
+	files := RenderPlan(plan, dir, width, height)
+	assert.Equal(t, len(plan.Order), len(files))
+
+	// the images of the later steps contain more blocks
+	previousRatio := 1.0
+	for _, file := range files {
+		infile, err := os.Open(file)
+		assert.Nil(t, err)
+		defer infile.Close()
+		img, _, errPng := image.Decode(infile)
+
+		assert.Nil(t, errPng)
+		assert.Equal(t, width, img.Bounds().Dx())
+		assert.Equal(t, height, img.Bounds().Dy())
+
+		blackRatio := getPixelRatio(img, 0, 0, 0)
+		assert.True(t, blackRatio < previousRatio)
+		previousRatio = blackRatio
+	}
+}
+
 // Returns the ratio of pixels that have the given color.
 // Value between 0 and 1
 func getPixelRatio(img image.Image, red, green, blue uint32) float64 {
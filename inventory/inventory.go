@@ -0,0 +1,229 @@
+// Package inventory contains the type I, the number of copies of each block available
+// when playing, e.g. the contents of the box of the original game
+package inventory
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ubongo/blockfactory"
+	"ubongo/blockset"
+)
+
+// I is an inventory: the number of copies available per block number
+type I struct {
+	counts map[int]int
+}
+
+// New creates an inventory from the given counts (map[BlockNumber]Count).
+// Panics if a count is negative
+func New(counts map[int]int) *I {
+	i := &I{counts: make(map[int]int)}
+	for number, count := range counts {
+		i.Set(number, count)
+	}
+	return i
+}
+
+// Ubongo returns the inventory of the original Ubongo game
+func Ubongo() *I {
+	return New(map[int]int{
+		1:  2, // yellow hello
+		2:  2, // yellow bighook
+		3:  3, // yellow smallhook
+		4:  2, // yellow gate
+		5:  2, // blue bighook
+		6:  2, // blue flash
+		7:  3, // blue lighter
+		8:  4, // blue v
+		9:  3, // red stool
+		10: 3, // red smallhook
+		11: 2, // red bighook
+		12: 2, // red flash
+		13: 2, // green flash
+		14: 2, // green bighook
+		15: 2, // green T
+		16: 4, // green L
+	})
+}
+
+// Load reads an inventory from the given file, see Parse for the format
+func Load(file string) (*I, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads an inventory with one line per block: the block number and the number
+// of copies, separated by whitespace. Empty lines and everything after a '#' are ignored.
+// Block numbers not known by the block factory are an error
+func Parse(r io.Reader) (*I, error) {
+	bf := blockfactory.Get()
+	i := New(nil)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected block number and count", line)
+		}
+		number, err := strconv.Atoi(fields[0])
+		if err != nil || number < bf.MinBlockNumber || number > bf.MaxBlockNumber {
+			return nil, fmt.Errorf("line %d: invalid block number %q", line, fields[0])
+		}
+		count, err := strconv.Atoi(fields[1])
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("line %d: invalid count %q", line, fields[1])
+		}
+		i.Set(number, i.Count(number)+count)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// Save writes the inventory to the given file in the format read by Load
+func (i *I) Save(file string) error {
+	s := "# block number, count\n"
+	for _, number := range i.Numbers() {
+		s += fmt.Sprintf("%d %d\n", number, i.Count(number))
+	}
+	return os.WriteFile(file, []byte(s), 0644)
+}
+
+// String returns a string representation of the inventory
+func (i *I) String() string {
+	if i == nil {
+		return "(nil)"
+	} else {
+		s := "{"
+		for idx, number := range i.Numbers() {
+			if idx != 0 {
+				s += ", "
+			}
+			s += fmt.Sprintf("%d: %d", number, i.Count(number))
+		}
+		return s + "}"
+	}
+}
+
+// Count returns the number of copies of the block with the given number
+func (i *I) Count(number int) int {
+	if i == nil {
+		return 0
+	}
+	return i.counts[number]
+}
+
+// Set sets the number of copies of the block with the given number, 0 removes the block.
+// Panics if count is negative
+func (i *I) Set(number, count int) {
+	if count < 0 {
+		panic("Count must be >= 0")
+	}
+	if count == 0 {
+		delete(i.counts, number)
+	} else {
+		i.counts[number] = count
+	}
+}
+
+// Numbers returns the numbers of the blocks available, in ascending order
+func (i *I) Numbers() []int {
+	numbers := make([]int, 0)
+	if i == nil {
+		return numbers
+	}
+	for number := range i.counts {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// Counts returns a copy of the counts (map[BlockNumber]Count)
+func (i *I) Counts() map[int]int {
+	counts := make(map[int]int)
+	if i == nil {
+		return counts
+	}
+	for number, count := range i.counts {
+		counts[number] = count
+	}
+	return counts
+}
+
+// Total returns the number of blocks in the inventory, counting all copies
+func (i *I) Total() int {
+	total := 0
+	for _, number := range i.Numbers() {
+		total += i.Count(number)
+	}
+	return total
+}
+
+// Clone returns a deep copy of the inventory
+func (i *I) Clone() *I {
+	if i == nil {
+		return nil
+	}
+	return New(i.counts)
+}
+
+// Combine returns a new inventory containing the blocks of both inventories,
+// e.g. the contents of two boxes
+func (i *I) Combine(o *I) *I {
+	result := i.Clone()
+	if result == nil {
+		result = New(nil)
+	}
+	for _, number := range o.Numbers() {
+		result.Set(number, result.Count(number)+o.Count(number))
+	}
+	return result
+}
+
+// Equals returns true if both inventories contain the same blocks
+func (i *I) Equals(o *I) bool {
+	if i == nil || o == nil {
+		return i == o
+	}
+	if len(i.counts) != len(o.counts) {
+		return false
+	}
+	for number, count := range i.counts {
+		if o.counts[number] != count {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains returns true if all blocks of the blocksets are available at the same time,
+// counting every copy of a block in a multiset
+func (i *I) Contains(sets ...*blockset.S) bool {
+	needed := make(map[int]int)
+	for _, bs := range sets {
+		for number, count := range bs.Counts() {
+			needed[number] += count
+		}
+	}
+	for number, count := range needed {
+		if count > i.Count(number) {
+			return false
+		}
+	}
+	return true
+}
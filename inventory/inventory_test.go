@@ -0,0 +1,100 @@
+package inventory_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"ubongo/blockfactory"
+	"ubongo/blockset"
+	. "ubongo/inventory"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUbongo(t *testing.T) {
+	inv := Ubongo()
+	assert.Equal(t, 16, len(inv.Numbers()))
+	assert.Equal(t, 40, inv.Total())
+	assert.Equal(t, 4, inv.Count(8))
+	assert.Equal(t, 0, inv.Count(17))
+}
+
+func TestNew(t *testing.T) {
+	counts := map[int]int{1: 2, 3: 0, 5: 1}
+	inv := New(counts)
+	assert.Equal(t, []int{1, 5}, inv.Numbers())
+	assert.Equal(t, map[int]int{1: 2, 5: 1}, inv.Counts())
+
+	// the inventory doesn't share the map
+	counts[1] = 7
+	assert.Equal(t, 2, inv.Count(1))
+
+	inv.Set(5, 0)
+	inv.Set(2, 3)
+	assert.Equal(t, []int{1, 2}, inv.Numbers())
+	assert.Equal(t, "{1: 2, 2: 3}", inv.String())
+
+	assert.Panics(t, func() { New(map[int]int{1: -1}) })
+	assert.Panics(t, func() { inv.Set(1, -1) })
+
+	var nilInventory *I
+	assert.Equal(t, "(nil)", nilInventory.String())
+	assert.Equal(t, 0, nilInventory.Count(1))
+	assert.Equal(t, 0, nilInventory.Total())
+	assert.Nil(t, nilInventory.Clone())
+}
+
+func TestCombine(t *testing.T) {
+	// two boxes, one of them missing a piece
+	missing := Ubongo()
+	missing.Set(8, missing.Count(8)-1)
+	both := Ubongo().Combine(missing)
+	assert.Equal(t, 7, both.Count(8))
+	assert.Equal(t, 4, both.Count(1))
+	assert.Equal(t, 79, both.Total())
+	assert.Equal(t, 4, Ubongo().Count(8))
+
+	var nilInventory *I
+	assert.True(t, nilInventory.Combine(Ubongo()).Equals(Ubongo()))
+	assert.False(t, both.Equals(Ubongo()))
+	assert.True(t, both.Clone().Equals(both))
+}
+
+func TestContains(t *testing.T) {
+	f := blockfactory.Get()
+	inv := New(map[int]int{f.Blue_v.Number: 2, f.Green_L.Number: 1})
+
+	assert.True(t, inv.Contains(blockset.New(f.Blue_v, f.Green_L)))
+	assert.True(t, inv.Contains(blockset.New(f.Blue_v), blockset.New(f.Blue_v, f.Green_L)))
+	assert.False(t, inv.Contains(blockset.NewMultiset(f.Blue_v, f.Blue_v, f.Blue_v)))
+	assert.False(t, inv.Contains(blockset.New(f.Green_L), blockset.New(f.Green_L)))
+	assert.False(t, inv.Contains(blockset.New(f.Red_stool)))
+	assert.True(t, inv.Contains())
+}
+
+func TestParse(t *testing.T) {
+	inv, err := Parse(strings.NewReader("# two blue v\n8 2\n\n16 1 # green L\n8 1\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[int]int{8: 3, 16: 1}, inv.Counts())
+
+	for _, s := range []string{"8", "8 2 1", "x 2", "0 2", "17 2", "99 1", "8 x", "8 -1"} {
+		_, err := Parse(strings.NewReader(s))
+		assert.NotNil(t, err, s)
+	}
+
+	_, err = Parse(strings.NewReader("8 2\n# unknown\n17 1\n"))
+	assert.Equal(t, `line 3: invalid block number "17"`, err.Error())
+}
+
+func TestSaveLoad(t *testing.T) {
+	file := "inventory_test.txt"
+	defer os.Remove(file)
+
+	assert.Nil(t, Ubongo().Save(file))
+	inv, err := Load(file)
+	assert.Nil(t, err)
+	assert.True(t, inv.Equals(Ubongo()))
+
+	_, err = Load("does_not_exist.txt")
+	assert.NotNil(t, err)
+}
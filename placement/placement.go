@@ -0,0 +1,174 @@
+// Package placement contains the type T, a table listing for every block of the
+// game all positions in which it fits entirely into the volume of a problem shape
+package placement
+
+import (
+	"fmt"
+	"sync"
+
+	"ubongo/base/array2d"
+	"ubongo/base/bitmask"
+	"ubongo/base/vector"
+	"ubongo/block"
+	"ubongo/blockfactory"
+)
+
+// P is a single placement of a block shape inside the volume
+type P struct {
+	// ShapeIndex is the index of the shape in block.Shapes
+	ShapeIndex int
+
+	// Shift is the translation of the shape relative to the origin of the volume
+	Shift vector.V
+
+	// Mask contains the cells of the volume occupied by the shape at this position
+	Mask bitmask.M
+}
+
+// T is the table of all placements of the blocks in the volume of a given shape and height
+type T struct {
+	// Dim is the bounding box of the extruded volume
+	Dim vector.V
+
+	// Volume contains all cells of the extruded volume
+	Volume bitmask.M
+
+	// blocks contains the blocks of the factory the table was created for, by block number
+	blocks map[int]*block.B
+
+	// placements lists the placements by block number, ordered by shape index and shift
+	placements map[int][]P
+}
+
+// key identifies a table in the cache
+type key struct {
+	shape  string
+	height int
+}
+
+// cache contains all tables created by Get
+var cache = map[key]*T{}
+
+// cacheMutex protects the cache against concurrent access
+var cacheMutex sync.Mutex
+
+// Fits returns true if the bounding box of the volume with the given shape and height
+// has at most bitmask.Size cells, i.e. if a placement table can be created for it
+func Fits(shape *array2d.A, height int) bool {
+	return shape != nil && shape.DimX*shape.DimY*height <= bitmask.Size
+}
+
+// Get returns the placement table for the given shape and height. Tables are created
+// only once and shared by all callers, so the result must not be modified.
+// Panics if the volume doesn't fit into a bitmask, see Fits
+func Get(shape *array2d.A, height int) *T {
+	k := key{shape.String(), height}
+
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	if t, ok := cache[k]; ok {
+		return t
+	}
+	t := New(blockfactory.Get(), shape, height)
+	cache[k] = t
+	return t
+}
+
+// New creates the placement table for all blocks of the factory in the volume with
+// the given shape and height. Use Get to benefit from the cache.
+// Panics if the bounding box of the volume has more cells than a bitmask can hold, see Fits
+func New(bf *blockfactory.F, shape *array2d.A, height int) *T {
+	if bf == nil || shape == nil {
+		panic("BlockFactory and shape must not be nil")
+	}
+
+	volume := shape.Extrude(height)
+	t := &T{Dim: volume.GetBoundingBox(), blocks: make(map[int]*block.B), placements: make(map[int][]P)}
+	if t.Dim[0]*t.Dim[1]*t.Dim[2] > bitmask.Size {
+		panic(fmt.Sprintf("Volume %s is too big for a placement table (max %d cells)", t.Dim, bitmask.Size))
+	}
+
+	for x := 0; x < volume.DimX; x++ {
+		for y := 0; y < volume.DimY; y++ {
+			for z := 0; z < volume.DimZ; z++ {
+				if volume.Get(x, y, z) == 0 {
+					t.Volume = t.Volume.Set(t.Index(x, y, z))
+				}
+			}
+		}
+	}
+
+	for _, b := range bf.GetAll().AsSlice() {
+		t.blocks[b.Number] = b
+		t.placements[b.Number] = t.enumerate(b)
+	}
+
+	return t
+}
+
+// enumerate lists all placements of the block inside the volume, ordered by shape index and shift
+func (t *T) enumerate(b *block.B) []P {
+	placements := make([]P, 0)
+	for shapeIdx, s := range b.Shapes {
+		for _, shift := range t.Dim.GetShiftVectors(s.GetBoundingBox()) {
+			mask := bitmask.Zero
+			for x := 0; x < s.DimX; x++ {
+				for y := 0; y < s.DimY; y++ {
+					for z := 0; z < s.DimZ; z++ {
+						if s.Get(x, y, z) == 1 {
+							mask = mask.Set(t.Index(x+shift[0], y+shift[1], z+shift[2]))
+						}
+					}
+				}
+			}
+			if mask.AndNot(t.Volume).IsZero() {
+				placements = append(placements, P{shapeIdx, shift, mask})
+			}
+		}
+	}
+	return placements
+}
+
+// String returns a string representation of the table
+func (t *T) String() string {
+	if t == nil {
+		return "(nil)"
+	} else {
+		return fmt.Sprintf("Placement table (volume %s, %d cells, %d placements)", t.Dim, t.Volume.Count(), t.Count())
+	}
+}
+
+// Index returns the bit index of the cell (x,y,z) in the masks of the table
+func (t *T) Index(x, y, z int) int {
+	return (x*t.Dim[1]+y)*t.Dim[2] + z
+}
+
+// Cell returns the coordinates of the cell with the given bit index, the inverse of Index
+func (t *T) Cell(index int) vector.V {
+	return vector.V{index / (t.Dim[1] * t.Dim[2]), (index / t.Dim[2]) % t.Dim[1], index % t.Dim[2]}
+}
+
+// ForBlock returns the placements of the block, ordered by shape index and shift like the
+// solvers of the game package. The placements of the factory blocks are taken from the table,
+// the ones of other blocks are determined on every call
+func (t *T) ForBlock(b *block.B) []P {
+	if t == nil || b == nil {
+		return nil
+	}
+	if t.blocks[b.Number] == b {
+		return t.placements[b.Number]
+	}
+	return t.enumerate(b)
+}
+
+// Count returns the total number of placements of all blocks
+func (t *T) Count() int {
+	count := 0
+	if t != nil {
+		for _, p := range t.placements {
+			count += len(p)
+		}
+	}
+	return count
+}
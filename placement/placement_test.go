@@ -0,0 +1,115 @@
+package placement_test
+
+import (
+	"testing"
+	"ubongo/base/array2d"
+	"ubongo/base/array3d"
+	"ubongo/base/vector"
+	"ubongo/block"
+	"ubongo/blockfactory"
+	"ubongo/card"
+	"ubongo/cardfactory"
+	. "ubongo/placement"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	bf := blockfactory.Get()
+	shape := array2d.NewFromData([][]int8{{0, 0, -1}, {0, 0, 0}})
+	tbl := New(bf, shape, 2)
+
+	assert.Equal(t, vector.V{2, 3, 2}, tbl.Dim)
+	assert.Equal(t, 10, tbl.Volume.Count())
+
+	// every placement must lie within the volume and match the block's shape
+	for _, b := range bf.GetAll().AsSlice() {
+		for _, pl := range tbl.ForBlock(b) {
+			assert.Equal(t, b.Volume, pl.Mask.Count())
+			assert.True(t, pl.Mask.AndNot(tbl.Volume).IsZero())
+			s := b.Shapes[pl.ShapeIndex]
+			for x := 0; x < s.DimX; x++ {
+				for y := 0; y < s.DimY; y++ {
+					for z := 0; z < s.DimZ; z++ {
+						if s.Get(x, y, z) == 1 {
+							assert.True(t, pl.Mask.Get(tbl.Index(x+pl.Shift[0], y+pl.Shift[1], z+pl.Shift[2])))
+						}
+					}
+				}
+			}
+		}
+	}
+
+	assert.Less(t, 0, len(tbl.ForBlock(bf.Blue_v)))
+	assert.Nil(t, tbl.ForBlock(nil))
+
+	assert.Panics(t, func() { New(nil, shape, 2) })
+	assert.Panics(t, func() { New(bf, array2d.New(10, 10), 2) })
+}
+
+func TestForBlockCustom(t *testing.T) {
+	bf := blockfactory.Get()
+	tbl := Get(array2d.New(3, 2), 2)
+
+	// a copy of a factory block has the same placements
+	v := *bf.Blue_v
+	assert.Equal(t, tbl.ForBlock(bf.Blue_v), tbl.ForBlock(&v))
+
+	// a block not known by the factory, a single cube fits into every cell
+	cube := array3d.New(1, 1, 1)
+	cube.Set(0, 0, 0, 1)
+	custom := &block.B{Number: 99, Name: "cube", Shapes: cube.CreateRotations(), Volume: 1}
+	assert.Equal(t, 12, len(tbl.ForBlock(custom)))
+}
+
+func TestFits(t *testing.T) {
+	assert.True(t, Fits(array2d.New(8, 8), 2))
+	assert.False(t, Fits(array2d.New(9, 8), 2))
+	assert.False(t, Fits(nil, 2))
+}
+
+func TestGet(t *testing.T) {
+	shape := cardfactory.Get().Get(card.Easy, 1).Problems[1].Shape
+
+	a := Get(shape, 2)
+	b := Get(shape.Clone(), 2)
+	c := Get(shape, 3)
+
+	assert.Same(t, a, b, "Table for the same shape and height must be cached")
+	assert.NotSame(t, a, c)
+	assert.Equal(t, 2, a.Dim[2])
+	assert.Equal(t, 3, c.Dim[2])
+}
+
+func TestIndexCell(t *testing.T) {
+	tbl := Get(array2d.New(4, 3), 2)
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 3; y++ {
+			for z := 0; z < 2; z++ {
+				assert.Equal(t, vector.V{x, y, z}, tbl.Cell(tbl.Index(x, y, z)))
+			}
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tbl := Get(array2d.New(2, 2), 2)
+	assert.True(t, len(tbl.String()) > 10)
+
+	var nilT *T = nil
+	assert.Equal(t, "(nil)", nilT.String())
+	assert.Equal(t, 0, nilT.Count())
+	assert.Nil(t, nilT.ForBlock(blockfactory.Get().Blue_v))
+}
+
+func TestCount(t *testing.T) {
+	bf := blockfactory.Get()
+	tbl := Get(array2d.New(2, 2), 2)
+
+	count := 0
+	for _, b := range bf.GetAll().AsSlice() {
+		count += len(tbl.ForBlock(b))
+	}
+	assert.Equal(t, count, tbl.Count())
+	assert.Less(t, 0, tbl.Count())
+}
@@ -23,6 +23,17 @@ func TestNew(t *testing.T) {
 	assert.True(t, bs.Equals(p.Blocks))
 }
 
+func TestNewMultiset(t *testing.T) {
+	bf := blockfactory.Get()
+	bs := blockset.NewMultiset(bf.Blue_v, bf.Blue_v, bf.Green_L)
+	p := New(array2d.New(5, 1), 2, bs)
+
+	assert.Equal(t, 3, p.Blocks.Count)
+	assert.Equal(t, 2, p.Blocks.CountOf(bf.Blue_v.Number))
+	assert.True(t, p.Equals(p.Clone()))
+	assert.False(t, p.Equals(New(array2d.New(5, 1), 2, blockset.New(bf.Blue_v, bf.Blue_v, bf.Green_L))))
+}
+
 func TestString(t *testing.T) {
 	bf := blockfactory.Get()
 	p := New(array2d.New(2, 2), 2, blockset.New(bf.Blue_flash))
@@ -0,0 +1,199 @@
+// Package symmetry contains the type G, the symmetry group of a problem volume, which is
+// used to identify solutions that are geometrically the same arrangement turned around
+package symmetry
+
+import (
+	"fmt"
+	"strings"
+
+	"ubongo/base/array3d"
+	"ubongo/base/vector"
+	"ubongo/gamesolution"
+	"ubongo/problem"
+)
+
+// rotation is a function rotating a 3D array
+type rotation func(a *array3d.A) *array3d.A
+
+// chain combines the given rotations into one, applying them from left to right
+func chain(rotations ...rotation) rotation {
+	return func(a *array3d.A) *array3d.A {
+		for _, r := range rotations {
+			a = r(a)
+		}
+		return a
+	}
+}
+
+// allRotations lists the 24 rotations of 3D space by multiples of 90° around the axes,
+// in the same order as used by array3d.CreateRotations. The first one is the identity
+var allRotations = []rotation{
+	chain(),
+	chain((*array3d.A).RotateZ),
+	chain((*array3d.A).RotateZ2),
+	chain((*array3d.A).RotateZ3),
+
+	chain((*array3d.A).RotateX),
+	chain((*array3d.A).RotateX, (*array3d.A).RotateZ),
+	chain((*array3d.A).RotateX, (*array3d.A).RotateZ2),
+	chain((*array3d.A).RotateX, (*array3d.A).RotateZ3),
+
+	chain((*array3d.A).RotateX2),
+	chain((*array3d.A).RotateX2, (*array3d.A).RotateZ),
+	chain((*array3d.A).RotateX2, (*array3d.A).RotateZ2),
+	chain((*array3d.A).RotateX2, (*array3d.A).RotateZ3),
+
+	chain((*array3d.A).RotateX3),
+	chain((*array3d.A).RotateX3, (*array3d.A).RotateZ),
+	chain((*array3d.A).RotateX3, (*array3d.A).RotateZ2),
+	chain((*array3d.A).RotateX3, (*array3d.A).RotateZ3),
+
+	chain((*array3d.A).RotateY),
+	chain((*array3d.A).RotateY, (*array3d.A).RotateZ),
+	chain((*array3d.A).RotateY, (*array3d.A).RotateZ2),
+	chain((*array3d.A).RotateY, (*array3d.A).RotateZ3),
+
+	chain((*array3d.A).RotateY3),
+	chain((*array3d.A).RotateY3, (*array3d.A).RotateZ),
+	chain((*array3d.A).RotateY3, (*array3d.A).RotateZ2),
+	chain((*array3d.A).RotateY3, (*array3d.A).RotateZ3),
+}
+
+// G is the symmetry group of a problem volume: all rotations that map the volume onto itself.
+// Mirror images are not included, as the blocks cannot be mirrored
+type G struct {
+	// dim is the bounding box of the volume, used to map cells to indices
+	dim vector.V
+
+	// perms contains for each symmetry the new cell index of every cell index,
+	// perms[0] is the identity
+	perms [][]int
+}
+
+// New determines the symmetry group of the extruded volume of the given problem
+func New(p *problem.P) *G {
+	if p == nil {
+		panic("Problem must not be nil")
+	}
+
+	volume := p.Shape.Extrude(p.Height)
+	g := &G{dim: volume.GetBoundingBox(), perms: make([][]int, 0)}
+
+	// arrays containing one coordinate of each element as value, the rotated arrays
+	// tell the original position of every cell
+	coords := make([]*array3d.A, 3)
+	for axis := range coords {
+		coords[axis] = volume.Apply(func(x, y, z int, _ int8) int8 {
+			return int8(vector.V{x, y, z}[axis])
+		})
+	}
+
+	for _, rotate := range allRotations {
+		if !rotate(volume).Equals(volume) {
+			continue
+		}
+		rx, ry, rz := rotate(coords[0]), rotate(coords[1]), rotate(coords[2])
+		perm := make([]int, g.dim[0]*g.dim[1]*g.dim[2])
+		for x := 0; x < rx.DimX; x++ {
+			for y := 0; y < rx.DimY; y++ {
+				for z := 0; z < rx.DimZ; z++ {
+					perm[g.index(int(rx.Get(x, y, z)), int(ry.Get(x, y, z)), int(rz.Get(x, y, z)))] = g.index(x, y, z)
+				}
+			}
+		}
+		g.perms = append(g.perms, perm)
+	}
+	return g
+}
+
+// index returns the index of the cell (x,y,z) in the permutations, the same as in a placement table
+func (g *G) index(x, y, z int) int {
+	return (x*g.dim[1]+y)*g.dim[2] + z
+}
+
+// String returns a string representation of the group
+func (g *G) String() string {
+	if g == nil {
+		return "(nil)"
+	} else {
+		return fmt.Sprintf("Symmetry group of order %d", g.Order())
+	}
+}
+
+// Order returns the number of symmetries of the volume, including the identity
+func (g *G) Order() int {
+	if g == nil {
+		return 0
+	}
+	return len(g.perms)
+}
+
+// Key returns a canonical representation of the given solution, which is identical for all
+// solutions that can be transformed into each other by a symmetry of the volume
+func (g *G) Key(sol *gamesolution.S) string {
+	if g == nil || sol == nil {
+		return ""
+	}
+
+	// label each cell with the index of the block covering it, -1 for empty cells
+	cellCount := len(g.perms[0])
+	labels := make([]int, cellCount)
+	for i := range labels {
+		labels[i] = -1
+	}
+	for i, b := range sol.Blocks {
+		shape := b.Shapes[sol.ShapeIndex[i]]
+		shift := sol.Shifts[i]
+		for x := 0; x < shape.DimX; x++ {
+			for y := 0; y < shape.DimY; y++ {
+				for z := 0; z < shape.DimZ; z++ {
+					if shape.Get(x, y, z) == 1 {
+						labels[g.index(x+shift[0], y+shift[1], z+shift[2])] = i
+					}
+				}
+			}
+		}
+	}
+
+	key := ""
+	transformed := make([]int, cellCount)
+	for _, perm := range g.perms {
+		for cell, label := range labels {
+			transformed[perm[cell]] = label
+		}
+
+		// describe every cell by the number of the block covering it and the order in which
+		// the blocks appear, so identical blocks can be exchanged
+		var sb strings.Builder
+		order := make(map[int]int)
+		for _, label := range transformed {
+			if label < 0 {
+				sb.WriteString(".")
+				continue
+			}
+			if _, ok := order[label]; !ok {
+				order[label] = len(order)
+			}
+			fmt.Fprintf(&sb, "%d:%d,", sol.Blocks[label].Number, order[label])
+		}
+		if s := sb.String(); key == "" || s < key {
+			key = s
+		}
+	}
+	return key
+}
+
+// Distinct returns one representative of each class of symmetric solutions, keeping the
+// first solution of each class in the order given
+func (g *G) Distinct(solutions []*gamesolution.S) []*gamesolution.S {
+	result := make([]*gamesolution.S, 0)
+	seen := make(map[string]bool)
+	for _, sol := range solutions {
+		key := g.Key(sol)
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, sol)
+		}
+	}
+	return result
+}
@@ -0,0 +1,79 @@
+package symmetry_test
+
+import (
+	"testing"
+	"ubongo/base/array2d"
+	"ubongo/blockfactory"
+	"ubongo/blockset"
+	"ubongo/card"
+	"ubongo/cardfactory"
+	"ubongo/game"
+	"ubongo/gamesolution"
+	"ubongo/problem"
+	. "ubongo/symmetry"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	f := blockfactory.Get()
+
+	// a cube has all 24 rotations as symmetries
+	cube := problem.New(array2d.New(2, 2), 2, blockset.New(f.Blue_v))
+	assert.Equal(t, 24, New(cube).Order())
+
+	// a 3x2x2 box can be turned around its long axis and flipped end over end
+	box := problem.New(array2d.New(3, 2), 2, blockset.New(f.Blue_v))
+	assert.Equal(t, 8, New(box).Order())
+
+	// a 3x2x1 box only has the identity and the three 180° rotations
+	flat := problem.New(array2d.New(3, 2), 1, blockset.New(f.Blue_v))
+	assert.Equal(t, 4, New(flat).Order())
+
+	// an L-shaped volume is only symmetric under the identity and the swap of the two legs
+	l := problem.New(array2d.NewFromData([][]int8{{0, 0}, {0, -1}}), 2, blockset.New(f.Blue_v))
+	assert.Equal(t, 2, New(l).Order())
+
+	// a volume too big for a placement table
+	big := problem.New(array2d.New(9, 9), 2, blockset.New(f.Blue_v))
+	assert.Equal(t, 8, New(big).Order())
+
+	assert.Panics(t, func() { New(nil) })
+}
+
+func TestNil(t *testing.T) {
+	var g *G = nil
+	assert.Equal(t, "(nil)", g.String())
+	assert.Equal(t, 0, g.Order())
+	assert.Equal(t, "", g.Key(nil))
+}
+
+func TestKey(t *testing.T) {
+	p := cardfactory.Get().Get(card.Easy, 36).Problems[8]
+	g := New(p)
+	solutions := game.New(p).Solve()
+
+	// every solution has the same key as its symmetric counterparts, but different
+	// keys than the other solutions
+	keys := make(map[string]int)
+	for _, sol := range solutions {
+		assert.NotEqual(t, "", g.Key(sol))
+		keys[g.Key(sol)]++
+	}
+	for _, count := range keys {
+		assert.Equal(t, g.Order(), count)
+	}
+	assert.Equal(t, g.Order()*len(keys), len(solutions))
+}
+
+func TestDistinct(t *testing.T) {
+	p := cardfactory.Get().Get(card.Easy, 36).Problems[8]
+	g := New(p)
+	solutions := game.New(p).Solve()
+
+	distinct := g.Distinct(solutions)
+	assert.Equal(t, 6, len(solutions))
+	assert.Equal(t, 3, len(distinct))
+	assert.Equal(t, solutions[0], distinct[0], "Expected the first solution to be kept")
+	assert.Equal(t, 0, len(g.Distinct([]*gamesolution.S{})))
+}